@@ -0,0 +1,7 @@
+//go:build !linux
+
+package runutil
+
+func (cg *Cgroup) setup(x *execution) error {
+	return ErrNotSupported
+}
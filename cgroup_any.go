@@ -0,0 +1,30 @@
+package runutil
+
+import "time"
+
+// Cgroup describes a cgroup v2 created for each run of a command. Limits left to
+// zero are not applied. The parent cgroup must be writable by the current user,
+// for example a delegated systemd scope, and have the needed controllers available.
+//
+// A cgroup containing processes can't enable controllers such as memory for its
+// children, so unless these controllers are already enabled in the cgroup of the
+// current process, Parent has to be set to a delegated cgroup without processes.
+type Cgroup struct {
+	Parent    string  // path of the parent cgroup, defaults to the cgroup of the current process
+	MemoryMax int64   // memory.max in bytes
+	CPUMax    float64 // cpu.max as a number of CPUs, for example 0.5 for half a CPU
+	PidsMax   int64   // pids.max
+
+	// Report, if set, is called with the resource usage of the cgroup once the
+	// command exited, before the cgroup is removed
+	Report func(*CgroupStats)
+}
+
+// CgroupStats is the resource usage of a command run in a Cgroup
+type CgroupStats struct {
+	Path       string        // path of the cgroup, which no longer exists
+	MemoryPeak int64         // from memory.peak, -1 if not available
+	CPUUsage   time.Duration // usage_usec of cpu.stat
+	UserTime   time.Duration // user_usec of cpu.stat
+	SystemTime time.Duration // system_usec of cpu.stat
+}
@@ -0,0 +1,216 @@
+package runutil
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// cgroupPeriod is the period used for cpu.max, in microseconds
+const cgroupPeriod = 100000
+
+var cgroupSeq uint64
+
+// setup creates the cgroup for x and arranges for the command to be started in it
+// with CLONE_INTO_CGROUP, and for the cgroup to be removed once it exits
+func (cg *Cgroup) setup(x *execution) error {
+	parent := cg.Parent
+	if parent == "" {
+		var err error
+		parent, err = selfCgroup()
+		if err != nil {
+			return err
+		}
+	}
+
+	dir := filepath.Join(parent, fmt.Sprintf("runutil-%d-%d", os.Getpid(), atomic.AddUint64(&cgroupSeq, 1)))
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+
+	if err := cg.apply(parent, dir); err != nil {
+		os.Remove(dir)
+		return err
+	}
+
+	f, err := os.Open(dir)
+	if err != nil {
+		os.Remove(dir)
+		return err
+	}
+
+	x.cmd.SysProcAttr.UseCgroupFD = true
+	x.cmd.SysProcAttr.CgroupFD = int(f.Fd())
+
+	x.exit = append(x.exit, func(err error) error {
+		f.Close()
+		if cg.Report != nil {
+			cg.Report(readCgroupStats(dir))
+		}
+		removeCgroup(dir)
+		return err
+	})
+	return nil
+}
+
+// apply enables the controllers needed in parent and writes the limits to dir
+func (cg *Cgroup) apply(parent, dir string) error {
+	var limits [][2]string
+	if cg.MemoryMax > 0 {
+		limits = append(limits, [2]string{"memory", strconv.FormatInt(cg.MemoryMax, 10)})
+	}
+	if cg.CPUMax > 0 {
+		quota := int64(cg.CPUMax * cgroupPeriod)
+		if quota < 1000 {
+			// minimum allowed by the kernel
+			quota = 1000
+		}
+		limits = append(limits, [2]string{"cpu", fmt.Sprintf("%d %d", quota, cgroupPeriod)})
+	}
+	if cg.PidsMax > 0 {
+		limits = append(limits, [2]string{"pids", strconv.FormatInt(cg.PidsMax, 10)})
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+
+	enabled, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return err
+	}
+	ctrl := strings.Fields(string(enabled))
+
+	for _, l := range limits {
+		if !hasString(ctrl, l[0]) {
+			if err := enableController(parent, l[0]); err != nil {
+				return err
+			}
+		}
+		if err := os.WriteFile(filepath.Join(dir, l[0]+".max"), []byte(l[1]), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enableController enables ctrl for the children of parent
+func enableController(parent, ctrl string) error {
+	err := os.WriteFile(filepath.Join(parent, "cgroup.subtree_control"), []byte("+"+ctrl), 0644)
+	if errors.Is(err, syscall.EBUSY) {
+		// cgroup v2 doesn't allow processes in a cgroup delegating domain controllers
+		return fmt.Errorf("failed to enable %s controller in %s as it contains processes, Cgroup.Parent must be set to a delegated cgroup without processes: %w", ctrl, parent, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to enable %s controller in %s: %w", ctrl, parent, err)
+	}
+	return nil
+}
+
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// selfCgroup returns the path of the cgroup v2 the current process belongs to
+func selfCgroup() (string, error) {
+	buf, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+
+	var path string
+	found := false
+	s := bufio.NewScanner(bytes.NewReader(buf))
+	for s.Scan() {
+		// the cgroup v2 hierarchy has ID 0 and no controller
+		if strings.HasPrefix(s.Text(), "0::") {
+			path, found = s.Text()[3:], true
+			break
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("cgroup v2 not found in /proc/self/cgroup")
+	}
+
+	buf, err = os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	s = bufio.NewScanner(bytes.NewReader(buf))
+	for s.Scan() {
+		// ID parentID major:minor root mountpoint options... - fstype source superoptions
+		line := s.Text()
+		p := strings.Index(line, " - ")
+		if p == -1 || !strings.HasPrefix(line[p+3:], "cgroup2 ") {
+			continue
+		}
+		fields := strings.Fields(line[:p])
+		if len(fields) < 5 {
+			continue
+		}
+		if root := fields[3]; root != "/" {
+			path = strings.TrimPrefix(path, root)
+		}
+		return filepath.Join(fields[4], path), nil
+	}
+	return "", fmt.Errorf("cgroup v2 is not mounted")
+}
+
+func readCgroupStats(dir string) *CgroupStats {
+	st := &CgroupStats{Path: dir, MemoryPeak: -1}
+
+	if buf, err := os.ReadFile(filepath.Join(dir, "memory.peak")); err == nil {
+		if v, err := strconv.ParseInt(strings.TrimSpace(string(buf)), 10, 64); err == nil {
+			st.MemoryPeak = v
+		}
+	}
+
+	if buf, err := os.ReadFile(filepath.Join(dir, "cpu.stat")); err == nil {
+		// lines are made of "key value"
+		for _, line := range strings.Split(string(buf), "\n") {
+			k, v, _ := strings.Cut(line, " ")
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch k {
+			case "usage_usec":
+				st.CPUUsage = time.Duration(n) * time.Microsecond
+			case "user_usec":
+				st.UserTime = time.Duration(n) * time.Microsecond
+			case "system_usec":
+				st.SystemTime = time.Duration(n) * time.Microsecond
+			}
+		}
+	}
+	return st
+}
+
+// removeCgroup removes dir, killing anything the command left running in it
+func removeCgroup(dir string) {
+	err := os.Remove(dir)
+	if err == nil || !errors.Is(err, syscall.EBUSY) {
+		return
+	}
+
+	os.WriteFile(filepath.Join(dir, "cgroup.kill"), []byte("1"), 0644)
+	for i := 0; i < 100; i++ {
+		if buf, err := os.ReadFile(filepath.Join(dir, "cgroup.events")); err == nil && bytes.Contains(buf, []byte("populated 0")) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	os.Remove(dir)
+}
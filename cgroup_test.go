@@ -0,0 +1,107 @@
+//go:build linux
+
+package runutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testCgroup returns a parent cgroup usable for tests, or skips the test
+func testCgroup(t *testing.T) string {
+	parent, err := selfCgroup()
+	if err != nil {
+		t.Skipf("cgroup v2 not available: %s", err)
+	}
+	dir, err := os.MkdirTemp(parent, "runutil-test-")
+	if err != nil {
+		t.Skipf("cgroup %s is not delegated to us: %s", parent, err)
+	}
+	os.Remove(dir)
+	return parent
+}
+
+func TestCgroup(t *testing.T) {
+	parent := testCgroup(t)
+
+	var st *CgroupStats
+	res, err := Cmd("/bin/sh", "-c", "cat /proc/self/cgroup").Cgroup(&Cgroup{Report: func(s *CgroupStats) { st = s }}).Get()
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	if st == nil {
+		t.Fatalf("failed, stats were not reported")
+	}
+	if !strings.HasPrefix(st.Path, parent+"/runutil-") {
+		t.Errorf("invalid cgroup path %s", st.Path)
+	}
+	if !strings.Contains(string(res), "0::") || !strings.HasSuffix(strings.TrimSpace(string(res)), "/"+filepath.Base(st.Path)) {
+		t.Errorf("failed, the command did not run in %s: %s", st.Path, res)
+	}
+	if _, err := os.Stat(st.Path); !os.IsNotExist(err) {
+		t.Errorf("failed, cgroup %s was not removed", st.Path)
+	}
+}
+
+func TestCgroupLimits(t *testing.T) {
+	parent := testCgroup(t)
+	if buf, _ := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control")); !strings.Contains(string(buf), "pids") {
+		t.Skipf("pids controller not enabled in %s", parent)
+	}
+
+	// forking past the limit should fail
+	err := Cmd("/bin/sh", "-c", "for i in 1 2 3 4 5 6 7 8; do sleep 1 & done; wait").Cgroup(&Cgroup{PidsMax: 4}).Run()
+	if err == nil {
+		t.Errorf("failed, the command was supposed to hit the pids limit")
+	}
+}
+
+func TestCgroupMemory(t *testing.T) {
+	self := testCgroup(t)
+
+	// the parent must not have processes for the memory controller to be enabled
+	parent, err := os.MkdirTemp(self, "runutil-test-")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	defer os.Remove(parent)
+	if buf, _ := os.ReadFile(filepath.Join(parent, "cgroup.controllers")); !strings.Contains(string(buf), "memory") {
+		t.Skipf("memory controller not available in %s", parent)
+	}
+
+	var st *CgroupStats
+	cg := &Cgroup{Parent: parent, MemoryMax: 64 << 20, Report: func(s *CgroupStats) { st = s }}
+	res, err := Cmd("/bin/sh", "-c", "x=$(head -c 16000000 /dev/zero | tr '\\0' a); echo ${#x}").Cgroup(cg).Get()
+	if err != nil || strings.TrimSpace(string(res)) != "16000000" {
+		t.Fatalf("failed to run test: %q %v", res, err)
+	}
+	if st == nil {
+		t.Fatalf("failed, stats were not reported")
+	}
+	if st.MemoryPeak != -1 && (st.MemoryPeak < 16000000 || st.MemoryPeak > cg.MemoryMax) {
+		t.Errorf("invalid memory peak %d", st.MemoryPeak)
+	}
+
+	// using more than the limit either fails or is kept under it with swap
+	st = nil
+	err = Cmd("/bin/sh", "-c", "x=$(head -c 128000000 /dev/zero | tr '\\0' a); echo ${#x}").Cgroup(cg).Run()
+	if st == nil {
+		t.Fatalf("failed, stats were not reported")
+	}
+	if err == nil && st.MemoryPeak == -1 {
+		t.Errorf("failed, the command was supposed to hit the memory limit")
+	}
+	if st.MemoryPeak > cg.MemoryMax {
+		t.Errorf("failed, memory peak %d is above the limit", st.MemoryPeak)
+	}
+
+	// the cgroup of the test has processes, and is left untouched
+	if buf, _ := os.ReadFile(filepath.Join(self, "cgroup.subtree_control")); !strings.Contains(string(buf), "memory") {
+		err = Cmd("true").Cgroup(&Cgroup{MemoryMax: 64 << 20}).Run()
+		if err == nil || !strings.Contains(err.Error(), "Cgroup.Parent") {
+			t.Errorf("failed, expected an error asking for a parent cgroup, got %v", err)
+		}
+	}
+}
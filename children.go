@@ -0,0 +1,48 @@
+package runutil
+
+import (
+	"os/exec"
+	"sync"
+)
+
+// reapLk is held for reading while starting a command, and for writing while reaping
+// orphans, so a reaper can't see a child before it has been recorded in owned
+var reapLk sync.RWMutex
+
+// owned lists the pids of the commands started by this package, which are waited by
+// exec.Cmd and must not be collected by the reaper
+var (
+	ownedLk sync.Mutex
+	owned   = make(map[int]bool)
+)
+
+// startChild starts cmd and records it as owned
+func startChild(cmd *exec.Cmd) error {
+	reapLk.RLock()
+	defer reapLk.RUnlock()
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	ownedLk.Lock()
+	owned[cmd.Process.Pid] = true
+	ownedLk.Unlock()
+	return nil
+}
+
+// releaseChild must be called once cmd has been waited
+func releaseChild(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	ownedLk.Lock()
+	delete(owned, cmd.Process.Pid)
+	ownedLk.Unlock()
+}
+
+func isOwned(pid int) bool {
+	ownedLk.Lock()
+	defer ownedLk.Unlock()
+	return owned[pid]
+}
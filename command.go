@@ -0,0 +1,546 @@
+package runutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Command describes a command to be executed. It is created with Cmd and configured
+// with chained calls, then executed with one of Run, Read, Pipe, Get or Json.
+//
+//	out, err := runutil.Cmd("tar", "-c", ".").Dir(dir).Stderr(w).Timeout(time.Minute).Read()
+type Command struct {
+	args    []string
+	dir     string
+	env     Env
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+	tail    int // if >0, amount of stderr kept for ExitError
+	ctx     context.Context
+	timeout time.Duration
+	term    *TermPolicy
+	cgroup  *Cgroup
+	rlimits []RLimit
+	user    string
+	sandbox *Sandbox
+	closeOK bool
+	lineMax int
+}
+
+// Cmd returns a new Command running the given arguments. The first argument is
+// resolved using exec.LookPath.
+func Cmd(arg ...string) *Command {
+	return &Command{args: arg}
+}
+
+// Dir sets the working directory of the command. If empty, the current directory
+// of the calling process is used.
+func (c *Command) Dir(dir string) *Command {
+	c.dir = dir
+	return c
+}
+
+// Env sets the environment of the command. A nil Env means the OS's environ.
+func (c *Command) Env(e Env) *Command {
+	c.env = e
+	return c
+}
+
+// Stdin sets the input of the command
+func (c *Command) Stdin(r io.Reader) *Command {
+	c.stdin = r
+	return c
+}
+
+// Stdout sets where the output of the command goes when using Run or Start. It
+// defaults to os.Stdout for Run, and is ignored by the other methods as they
+// return the output.
+func (c *Command) Stdout(w io.Writer) *Command {
+	c.stdout = w
+	return c
+}
+
+// Stderr sets where the error output of the command goes. It defaults to os.Stderr,
+// except for Get which stores it in the returned *exec.ExitError, and CaptureStderr
+// which keeps it for the returned *ExitError.
+func (c *Command) Stderr(w io.Writer) *Command {
+	c.stderr = w
+	return c
+}
+
+// CaptureStderr keeps the last max bytes written by the command to stderr, and
+// returns them as part of an *ExitError if the command fails. If max is zero or
+// less, DefaultStderrTail is used. If a writer was set with Stderr, it will still
+// receive the whole output.
+func (c *Command) CaptureStderr(max int) *Command {
+	if max <= 0 {
+		max = DefaultStderrTail
+	}
+	c.tail = max
+	return c
+}
+
+// Context sets a context for the command. If the context is done before the command
+// completes, the command is killed and the returned error will wrap ctx.Err().
+func (c *Command) Context(ctx context.Context) *Command {
+	c.ctx = ctx
+	return c
+}
+
+// Timeout sets a maximum duration for the command, after which it'll be killed and
+// the returned error will wrap context.DeadlineExceeded.
+func (c *Command) Timeout(d time.Duration) *Command {
+	c.timeout = d
+	return c
+}
+
+// Terminate sets how the command is stopped when its context is done, or when its
+// output is closed early. By default the command is killed with SIGKILL.
+// The command then runs in its own process group even when waited on in the
+// foreground, so the signals are also sent to anything it spawned.
+func (c *Command) Terminate(t TermPolicy) *Command {
+	c.term = &t
+	return c
+}
+
+// Cgroup runs each invocation of the command in a new cgroup with the given limits.
+// The cgroup is removed once the command exits. Only supported on Linux with cgroup v2.
+func (c *Command) Cgroup(cg *Cgroup) *Command {
+	c.cgroup = cg
+	return c
+}
+
+// RLimits sets resource limits for the command. They are applied by running the
+// current executable again as a helper, which sets them and executes the command, as
+// Sandbox does. Hard limits can only be raised if the user the command runs as is
+// privileged. Only supported on Linux.
+func (c *Command) RLimits(l ...RLimit) *Command {
+	c.rlimits = l
+	return c
+}
+
+// RunAs runs the command as the given user, which can be a name or a numeric uid.
+// Its groups are set from the user database, and HOME, USER, LOGNAME and SHELL are
+// set in the command's Env from the passwd entry. Changing user usually requires
+// running as root. Not supported on Windows.
+func (c *Command) RunAs(user string) *Command {
+	c.user = user
+	return c
+}
+
+// Sandbox runs the command isolated in new user, mount, pid, network, uts and ipc
+// namespaces, as described by s. It works without privileges on systems allowing
+// unprivileged user namespaces. Only supported on Linux.
+//
+// The sandbox is set up by a helper, which is the current executable started again
+// through /proc/self/exe with a variable in its environment. The helper takes over
+// when this package is initialized, so the init functions of packages initialized
+// before it also run in the helper. It then sets up the sandbox and executes the
+// command.
+func (c *Command) Sandbox(s *Sandbox) *Command {
+	c.sandbox = s
+	return c
+}
+
+// IgnoreEarlyClose makes Close and CloseWait return nil instead of an error matching
+// ErrClosedByReader when the command was killed by SIGPIPE after its output was
+// closed before reaching EOF.
+func (c *Command) IgnoreEarlyClose() *Command {
+	c.closeOK = true
+	return c
+}
+
+// LineLength sets the maximum length of the lines returned by RunLines and
+// Pipe.Lines, longer lines being split. It defaults to MaxLineLength, and can't be
+// less than 16.
+func (c *Command) LineLength(n int) *Command {
+	c.lineMax = max(n, 16)
+	return c
+}
+
+// execution holds the state of a single run of a Command
+type execution struct {
+	cmd     *exec.Cmd
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stderr  *tailBuffer
+	term    *TermPolicy
+	group   bool                    // the command runs in its own process group
+	done    chan struct{}           // closed once the command completed
+	exit    []func(err error) error // called by finish once the command completed
+	rlimits []RLimit
+	helper  *helperConfig  // set when the command is started through the helper
+	started []func() error // called by startCmd once the command started
+	closed  atomic.Bool    // set when an output was closed by the reader before EOF
+	closeOK bool
+	lineMax int       // maximum length of lines
+	path    string    // resolved path of the command
+	startAt time.Time // when the command started
+	endAt   time.Time // when the command completed
+	o       sync.Once
+	e       error
+}
+
+// ctxError is returned when a command failed because its context was done
+type ctxError struct {
+	ctx error // ctx.Err()
+	err error
+}
+
+func (e *ctxError) Error() string {
+	return e.err.Error() + " (" + e.ctx.Error() + ")"
+}
+
+func (e *ctxError) Unwrap() []error {
+	return []error{e.ctx, e.err}
+}
+
+// closedError is returned when a command was killed by SIGPIPE after its output
+// was closed by the reader
+type closedError struct {
+	err error
+}
+
+func (e *closedError) Error() string {
+	return e.err.Error() + " (" + ErrClosedByReader.Error() + ")"
+}
+
+func (e *closedError) Unwrap() []error {
+	return []error{ErrClosedByReader, e.err}
+}
+
+// isSigpipe returns true if err is the result of a command killed by SIGPIPE
+func isSigpipe(err error) bool {
+	var e *exec.ExitError
+	if !errors.As(err, &e) {
+		return false
+	}
+	ws, ok := e.Sys().(syscall.WaitStatus)
+	return ok && ws.Signaled() && ws.Signal() == syscall.SIGPIPE
+}
+
+func (c *Command) prepare() (*execution, error) {
+	if len(c.args) == 0 {
+		return nil, ErrCommandMissing
+	}
+
+	cmd := c.args[0]
+	if c.sandbox == nil || c.sandbox.Root == "" {
+		// when chrooted, the command is looked up in the sandbox
+		var err error
+		if cmd, err = exec.LookPath(cmd); err != nil {
+			return nil, err
+		}
+	}
+
+	x := &execution{ctx: c.ctx, term: c.term, done: make(chan struct{}), closeOK: c.closeOK, path: cmd, lineMax: c.lineMax}
+	if x.lineMax == 0 {
+		x.lineMax = max(MaxLineLength, 16)
+	}
+	if c.timeout > 0 {
+		if x.ctx == nil {
+			x.ctx = context.Background()
+		}
+		x.ctx, x.cancel = context.WithTimeout(x.ctx, c.timeout)
+	}
+	if x.ctx != nil {
+		x.cmd = exec.CommandContext(x.ctx, cmd)
+		// cmd was already looked up, or has to be looked up in the sandbox
+		x.cmd.Path, x.cmd.Err = cmd, nil
+		x.cmd.Cancel = func() error {
+			return x.term.terminate(x.signal, x.done)
+		}
+	} else {
+		x.cmd = &exec.Cmd{Path: cmd}
+	}
+	x.setupProcAttr()
+	if c.term != nil {
+		x.ownGroup()
+	}
+	if c.cgroup != nil {
+		if err := c.cgroup.setup(x); err != nil {
+			return nil, x.finish(err)
+		}
+	}
+	if c.rlimits != nil {
+		if err := x.setupRLimits(c.rlimits); err != nil {
+			return nil, x.finish(err)
+		}
+	}
+
+	x.cmd.Args = c.args
+	x.cmd.Dir = c.dir
+	x.cmd.Env = []string(c.env)
+	x.cmd.Stdin = c.stdin
+	x.cmd.Stderr = c.stderr
+
+	if c.user != "" {
+		if err := x.setupUser(c.user); err != nil {
+			return nil, x.finish(err)
+		}
+	}
+	if c.sandbox != nil {
+		if err := x.setupSandbox(c.sandbox); err != nil {
+			return nil, x.finish(err)
+		}
+	}
+	if x.helper != nil {
+		if err := x.setupHelper(); err != nil {
+			return nil, x.finish(err)
+		}
+	}
+
+	if c.tail > 0 {
+		x.stderr = newTailBuffer(c.tail)
+		if c.stderr != nil {
+			x.cmd.Stderr = io.MultiWriter(c.stderr, x.stderr)
+		} else {
+			x.cmd.Stderr = x.stderr
+		}
+	}
+
+	return x, nil
+}
+
+// handoffStdin passes the file of a Pipe used as stdin directly to the command. Once
+// the command completed, the command writing to the Pipe is waited and its error
+// returned if this command succeeded, as when copying its output.
+func (x *execution) handoffStdin(h fileHandoff) {
+	f := h.File()
+	if f == nil {
+		return
+	}
+	x.cmd.Stdin = f
+
+	var wait func() error
+	x.started = append(x.started, func() error {
+		wait = h.handedOff()
+		return nil
+	})
+	x.exit = append(x.exit, func(err error) error {
+		if wait == nil {
+			// not started, the Pipe is left untouched
+			return err
+		}
+		uerr := wait()
+		if err == nil && uerr != nil && !errors.Is(uerr, ErrClosedByReader) {
+			return uerr
+		}
+		return err
+	})
+}
+
+// finish must be called once the command has completed, with the result of Wait
+func (x *execution) finish(err error) error {
+	x.endAt = time.Now()
+	close(x.done)
+	releaseChild(x.cmd)
+	for _, f := range x.exit {
+		err = f(err)
+	}
+	if x.closed.Load() && isSigpipe(err) {
+		// expected, as we closed the pipe the command was writing to
+		if x.closeOK {
+			err = nil
+		} else {
+			err = &closedError{err: err}
+		}
+	}
+	if err != nil && x.ctx != nil {
+		if cerr := x.ctx.Err(); cerr != nil && !errors.Is(err, cerr) {
+			err = &ctxError{ctx: cerr, err: err}
+		}
+	}
+	if x.cancel != nil {
+		x.cancel()
+	}
+	if x.stderr != nil {
+		var e *exec.ExitError
+		if errors.As(err, &e) {
+			err = &ExitError{Err: err, Stderr: x.stderr.Bytes()}
+		}
+	}
+	return err
+}
+
+// startCmd starts the command
+func (x *execution) startCmd() error {
+	// done here as Stdin may be replaced after prepare
+	if h, ok := x.cmd.Stdin.(fileHandoff); ok {
+		x.handoffStdin(h)
+	}
+
+	if err := startChild(x.cmd); err != nil {
+		return err
+	}
+	x.startAt = time.Now()
+
+	for _, f := range x.started {
+		if err := f(); err != nil {
+			x.cmd.Process.Kill()
+			x.cmd.Wait()
+			return err
+		}
+	}
+	return nil
+}
+
+// wait waits for the command to complete and returns its error. It can be called
+// multiple times.
+func (x *execution) wait() error {
+	x.o.Do(func() {
+		x.e = x.finish(x.cmd.Wait())
+	})
+	return x.e
+}
+
+// waitContext waits for the command to complete and returns its error. If ctx is
+// done first, the command is terminated according to its TermPolicy.
+func (x *execution) waitContext(ctx context.Context) error {
+	// wait() may already be running, in which case this returns with it
+	go x.wait()
+
+	select {
+	case <-x.done:
+	case <-ctx.Done():
+		x.term.terminate(x.signal, x.done)
+		// force wait after kill
+		<-x.done
+	}
+	return x.wait()
+}
+
+// kill sends SIGKILL to the command, if it didn't complete yet
+func (x *execution) kill() error {
+	select {
+	case <-x.done:
+		return os.ErrProcessDone
+	default:
+	}
+	return x.signal(syscall.SIGKILL)
+}
+
+// start starts the command with its output returned as a Pipe
+func (x *execution) start() (Pipe, error) {
+	r, err := x.cmd.StdoutPipe()
+	if err != nil {
+		return nil, x.finish(err)
+	}
+
+	if err = x.startCmd(); err != nil {
+		return nil, x.finish(err)
+	}
+
+	return newProcessPipe(r, x), nil
+}
+
+// Run executes the command and waits for it to complete
+func (c *Command) Run() error {
+	_, err := c.RunResult()
+	return err
+}
+
+// RunResult is the same as Run, and also returns the Result of the command if it
+// could be started
+func (c *Command) RunResult() (*Result, error) {
+	x, err := c.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	x.cmd.Stdout = c.stdout
+	if x.cmd.Stdout == nil {
+		x.cmd.Stdout = os.Stdout
+	}
+	if x.cmd.Stderr == nil {
+		x.cmd.Stderr = os.Stderr
+	}
+
+	if err = x.startCmd(); err != nil {
+		err = x.finish(err)
+		return x.result(), err
+	}
+	err = x.wait()
+	return x.result(), err
+}
+
+// Read executes the command in background and returns its output as a stream.
+// Close the stream to kill the command and release its resources.
+func (c *Command) Read() (Pipe, error) {
+	x, err := c.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	if x.cmd.Stderr == nil {
+		x.cmd.Stderr = os.Stderr
+	}
+
+	x.ownGroup()
+	return x.start()
+}
+
+// Pipe is the same as Read, and reads better when Stdin was set
+func (c *Command) Pipe() (Pipe, error) {
+	return c.Read()
+}
+
+// Get executes the command and returns its output as a buffer after it completes.
+func (c *Command) Get() ([]byte, error) {
+	buf, _, err := c.GetResult()
+	return buf, err
+}
+
+// GetResult is the same as Get, and also returns the Result of the command if it
+// could be started
+func (c *Command) GetResult() ([]byte, *Result, error) {
+	x, err := c.prepare()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	x.cmd.Stdout = buf
+
+	// same as exec.Cmd.Output, keep stderr in the returned *exec.ExitError
+	var stderr *tailBuffer
+	if x.cmd.Stderr == nil {
+		stderr = newTailBuffer(DefaultStderrTail)
+		x.cmd.Stderr = stderr
+	}
+
+	if err = x.startCmd(); err != nil {
+		err = x.finish(err)
+		return nil, x.result(), err
+	}
+	err = x.wait()
+
+	var e *exec.ExitError
+	if stderr != nil && errors.As(err, &e) {
+		e.Stderr = stderr.Bytes()
+	}
+	return buf.Bytes(), x.result(), err
+}
+
+// Json executes the command and applies its output to the specified object, parsing json data
+func (c *Command) Json(obj interface{}) error {
+	r, err := c.Read()
+	if err != nil {
+		return err
+	}
+	defer r.Close() // close pipe after we finish reading
+
+	// parse
+	dec := json.NewDecoder(r)
+	return dec.Decode(obj)
+}
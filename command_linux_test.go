@@ -0,0 +1,36 @@
+package runutil
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestCommandGroup(t *testing.T) {
+	self := strconv.Itoa(syscall.Getpgrp())
+	pgrp := "cut -d' ' -f5 /proc/$$/stat"
+
+	// foreground commands stay in our process group
+	res, err := Cmd("/bin/sh", "-c", pgrp).Get()
+	if err != nil || strings.TrimSpace(string(res)) != self {
+		t.Errorf("failed, expected the command to be in group %s, got %q %v", self, res, err)
+	}
+
+	// unless they have a TermPolicy
+	res, err = Cmd("/bin/sh", "-c", pgrp).Terminate(TermPolicy{}).Get()
+	if err != nil || strings.TrimSpace(string(res)) == self {
+		t.Errorf("failed, expected the command to have its own group, got %q %v", res, err)
+	}
+
+	// background commands have their own group
+	r, err := RunRead("/bin/sh", "-c", pgrp)
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	res, err = ioutil.ReadAll(r)
+	if err != nil || strings.TrimSpace(string(res)) == self {
+		t.Errorf("failed, expected the command to have its own group, got %q %v", res, err)
+	}
+}
@@ -0,0 +1,137 @@
+package runutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCommand(t *testing.T) {
+	res, err := Cmd("/bin/sh", "-c", "echo -n $PWD $FOO").Dir("/tmp").Env(Env{"FOO=bar"}).Get()
+	if err != nil {
+		t.Errorf("failed to run test: %s", err)
+		return
+	}
+
+	if string(res) != "/tmp bar" {
+		t.Errorf("invalid output, expected /tmp bar, got %s", res)
+	}
+
+	buf := &bytes.Buffer{}
+	err = Cmd("/bin/sh", "-c", "echo -n oops >&2; exit 3").Stderr(buf).Run()
+	if err == nil {
+		t.Errorf("failed, the command was supposed to return an error but didn't")
+	}
+	if buf.String() != "oops" {
+		t.Errorf("invalid stderr, expected oops, got %s", buf)
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	start := time.Now()
+	err := Cmd("sleep", "10").Timeout(100 * time.Millisecond).Run()
+	if err == nil {
+		t.Errorf("failed, the command was supposed to be killed but didn't")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("failed, the command wasn't killed in time")
+	}
+}
+
+func TestCaptureStderr(t *testing.T) {
+	res, err := Cmd("/bin/sh", "-c", "echo -n output; echo first line >&2; echo something went wrong >&2; exit 42").CaptureStderr(0).Read()
+	if err != nil {
+		t.Errorf("failed to run test: %s", err)
+		return
+	}
+
+	buf, err := ioutil.ReadAll(res)
+	if string(buf) != "output" {
+		t.Errorf("failed, buf did not contain the expected stuff")
+	}
+
+	var e *ExitError
+	if !errors.As(err, &e) {
+		t.Errorf("failed, the command was supposed to return an error of type ExitError, got %T (%v)", err, err)
+		return
+	}
+	if string(e.Stderr) != "first line\nsomething went wrong\n" {
+		t.Errorf("invalid stderr captured: %q", e.Stderr)
+	}
+	if err.Error() != "exit status 42: something went wrong" {
+		t.Errorf("invalid error message: %s", err)
+	}
+
+	var ee *exec.ExitError
+	if !errors.As(err, &ee) {
+		t.Errorf("failed, the command was supposed to return an error of type exec.ExitError, got %T (%s)", err, err)
+		return
+	}
+	if ee.ProcessState.ExitCode() != 42 {
+		t.Errorf("failed, the command was supposed to return error 42")
+	}
+
+	// only the tail should be kept
+	_, err = Cmd("/bin/sh", "-c", "echo 0123456789 >&2; exit 1").CaptureStderr(4).Get()
+	if !errors.As(err, &e) {
+		t.Errorf("failed, the command was supposed to return an error of type ExitError, got %T (%v)", err, err)
+		return
+	}
+	if string(e.Stderr) != "789\n" {
+		t.Errorf("invalid stderr captured: %q", e.Stderr)
+	}
+}
+
+func TestCommandTerminate(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// sleep runs in background, and should receive the signal too as part of the process group
+	res, err := Cmd("/bin/sh", "-c", "trap 'echo -n bye; exit 3' TERM; echo -n hi; sleep 10 & wait").
+		Context(ctx).
+		Terminate(TermPolicy{Signal: syscall.SIGTERM, Grace: 5 * time.Second}).
+		Read()
+	if err != nil {
+		t.Errorf("failed to run test: %s", err)
+		return
+	}
+
+	start := time.Now()
+	buf, err := ioutil.ReadAll(res)
+	if string(buf) != "hibye" {
+		t.Errorf("failed, buf did not contain the expected stuff: %s", buf)
+	}
+	if time.Since(start) > 4*time.Second {
+		t.Errorf("failed, the command wasn't terminated in time")
+	}
+
+	var e *exec.ExitError
+	if !errors.As(err, &e) {
+		t.Errorf("failed, the command was supposed to return an error of type exec.ExitError, got %T (%s)", err, err)
+		return
+	}
+	if e.ProcessState.ExitCode() != 3 {
+		t.Errorf("failed, the command was supposed to return error 3, got %s", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("failed, the command was supposed to return a deadline error, got %v", err)
+	}
+
+	// ignoring the first signal should result in being killed after the grace period
+	start = time.Now()
+	err = Cmd("/bin/sh", "-c", "trap '' TERM; sleep 10").
+		Timeout(100 * time.Millisecond).
+		Terminate(TermPolicy{Signal: syscall.SIGTERM, Grace: 100 * time.Millisecond}).
+		Run()
+	if !errors.As(err, &e) || e.ProcessState.String() != "signal: killed" {
+		t.Errorf("failed, the command was supposed to be killed, got %v", err)
+	}
+	if time.Since(start) > 4*time.Second {
+		t.Errorf("failed, the command wasn't killed in time")
+	}
+}
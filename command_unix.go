@@ -0,0 +1,37 @@
+//go:build !windows
+
+package runutil
+
+import (
+	"os"
+	"syscall"
+)
+
+func (x *execution) setupProcAttr() {
+	x.cmd.SysProcAttr = &syscall.SysProcAttr{}
+}
+
+// ownGroup runs the command in its own process group so signals can reach anything
+// it spawns. Commands running in the foreground stay in the group of the caller,
+// so they receive the signals of the terminal such as Ctrl-C, and can read from it.
+func (x *execution) ownGroup() {
+	x.cmd.SysProcAttr.Setpgid = true
+	x.group = true
+}
+
+// signal sends sig to the command, and to its process group if it has its own
+func (x *execution) signal(sig syscall.Signal) error {
+	if x.group {
+		return signalGroup(x.cmd.Process, sig)
+	}
+	return x.cmd.Process.Signal(sig)
+}
+
+// signalGroup sends sig to the process group of p
+func signalGroup(p *os.Process, sig syscall.Signal) error {
+	err := syscall.Kill(-p.Pid, sig)
+	if err == syscall.ESRCH {
+		return os.ErrProcessDone
+	}
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package runutil
+
+import "syscall"
+
+func (x *execution) setupProcAttr() {
+}
+
+func (x *execution) ownGroup() {
+}
+
+// signal can only kill the process on windows
+func (x *execution) signal(sig syscall.Signal) error {
+	return x.cmd.Process.Kill()
+}
@@ -18,7 +18,8 @@ func sysEnv() Env {
 	return Env(os.Environ())
 }
 
-// NewEnv returns an empty env with only HOME, PATH set
+// NewEnv returns an empty env with only HOME, PATH set. USER is guessed from home,
+// use Command.RunAs to run as an actual user with values from the user database.
 func NewEnv(home string, vars ...string) Env {
 	usr := "root"
 	if home != "/" {
@@ -5,4 +5,6 @@ import "errors"
 var (
 	ErrCommandMissing = errors.New("command is missing")
 	ErrNotSupported   = errors.New("operation not supported on this platform")
+	ErrProcessChanged = errors.New("process was replaced since it was looked up")
+	ErrClosedByReader = errors.New("output closed by reader before the command completed")
 )
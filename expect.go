@@ -0,0 +1,177 @@
+package runutil
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+)
+
+// Expecter drives an interactive command by waiting for its output to match
+// regular expressions and sending input in response.
+//
+//	e := p.Expecter()
+//	if _, err := e.Expect(ctx, regexp.MustCompile(`Password: $`)); err != nil {
+//		return err
+//	}
+//	e.Send(password + "\n")
+type Expecter struct {
+	r     io.Reader
+	w     io.Writer
+	log   io.Writer
+	logLk sync.Mutex
+
+	lk     sync.Mutex
+	buf    []byte // output received but not matched yet
+	err    error  // read error, once the output is over
+	notify chan struct{}
+	once   sync.Once
+}
+
+// ExpectError is returned by Expect when the output didn't match before the context
+// was done or the output ended. Output holds what was received and not matched.
+type ExpectError struct {
+	Err    error // ctx.Err(), io.EOF or the error of the command
+	Output []byte
+}
+
+func (e *ExpectError) Error() string {
+	out := e.Output
+	if len(out) > 256 {
+		out = out[len(out)-256:]
+	}
+	return fmt.Sprintf("expect: %s, unmatched output: %q", e.Err, out)
+}
+
+func (e *ExpectError) Unwrap() error {
+	return e.Err
+}
+
+// NewExpecter returns an Expecter reading the output of a command from r, and
+// sending its input to w
+func NewExpecter(r io.Reader, w io.Writer) *Expecter {
+	return &Expecter{r: r, w: w}
+}
+
+// Expecter returns an Expecter for the terminal
+func (p *Pty) Expecter() *Expecter {
+	return NewExpecter(p, p)
+}
+
+// Expecter returns an Expecter reading the process' stdout and writing to its stdin.
+// Neither must have been set on the Command.
+func (p *Process) Expecter() *Expecter {
+	return NewExpecter(p.stdout, p.stdin)
+}
+
+// SetLog sets a writer receiving a transcript of the session: the output of the
+// command as it's received, and what is sent to it, marked with [send "..."].
+func (e *Expecter) SetLog(w io.Writer) *Expecter {
+	e.log = w
+	return e
+}
+
+// Send writes s to the command's input
+func (e *Expecter) Send(s string) error {
+	e.logf("[send %q]", s)
+	_, err := io.WriteString(e.w, s)
+	return err
+}
+
+// Expect waits for the output to match re, and returns the match and its
+// submatches. The output up to the end of the match is consumed.
+func (e *Expecter) Expect(ctx context.Context, re *regexp.Regexp) ([]string, error) {
+	_, m, err := e.ExpectAny(ctx, re)
+	return m, err
+}
+
+// ExpectAny waits for the output to match any of the given cases, and returns the
+// index of the matching case, the match and its submatches. If several cases
+// match, the one matching first in the output is used.
+func (e *Expecter) ExpectAny(ctx context.Context, cases ...*regexp.Regexp) (int, []string, error) {
+	e.once.Do(func() {
+		e.notify = make(chan struct{}, 1)
+		go e.read()
+	})
+
+	for {
+		e.lk.Lock()
+		n, m := e.match(cases)
+		out, err := e.buf, e.err
+		e.lk.Unlock()
+
+		if n != -1 {
+			return n, m, nil
+		}
+		if err != nil {
+			return -1, nil, &ExpectError{Err: err, Output: append([]byte(nil), out...)}
+		}
+
+		select {
+		case <-e.notify:
+		case <-ctx.Done():
+			e.lk.Lock()
+			out = append([]byte(nil), e.buf...)
+			e.lk.Unlock()
+			return -1, nil, &ExpectError{Err: ctx.Err(), Output: out}
+		}
+	}
+}
+
+// match looks for the earliest match of cases in the buffer, and consumes it
+func (e *Expecter) match(cases []*regexp.Regexp) (int, []string) {
+	best := -1
+	var loc []int
+	for n, re := range cases {
+		l := re.FindSubmatchIndex(e.buf)
+		if l != nil && (loc == nil || l[0] < loc[0]) {
+			best, loc = n, l
+		}
+	}
+	if best == -1 {
+		return -1, nil
+	}
+
+	m := make([]string, len(loc)/2)
+	for i := range m {
+		if loc[2*i] >= 0 {
+			m[i] = string(e.buf[loc[2*i]:loc[2*i+1]])
+		}
+	}
+	e.buf = e.buf[loc[1]:]
+	return best, m
+}
+
+// read stores the output in the buffer until it ends, and notifies ExpectAny
+func (e *Expecter) read() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := e.r.Read(buf)
+		if n > 0 {
+			e.logf("%s", buf[:n])
+		}
+
+		e.lk.Lock()
+		e.buf = append(e.buf, buf[:n]...)
+		e.err = err
+		e.lk.Unlock()
+
+		select {
+		case e.notify <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (e *Expecter) logf(format string, arg ...interface{}) {
+	if e.log == nil {
+		return
+	}
+	e.logLk.Lock()
+	defer e.logLk.Unlock()
+	fmt.Fprintf(e.log, format, arg...)
+}
@@ -0,0 +1,79 @@
+//go:build linux
+
+package runutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const expectScript = `printf "Name: "; read name; printf "Hello %s\nContinue? [y/n] " "$name"; read a; [ "$a" = y ] && echo done; exit 2`
+
+func TestExpect(t *testing.T) {
+	p, err := Start("/bin/sh", "-c", expectScript)
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	defer p.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	log := &bytes.Buffer{}
+	e := p.Expecter().SetLog(log)
+
+	if _, err := e.Expect(ctx, regexp.MustCompile(`Name: $`)); err != nil {
+		t.Fatalf("failed to expect prompt: %s", err)
+	}
+	e.Send("world\n")
+
+	n, m, err := e.ExpectAny(ctx, regexp.MustCompile(`Password: `), regexp.MustCompile(`Hello (\w+)\n`))
+	if err != nil || n != 1 || m[1] != "world" {
+		t.Errorf("invalid match %d %v: %v", n, m, err)
+	}
+	if _, err := e.Expect(ctx, regexp.MustCompile(`\[y/n\] $`)); err != nil {
+		t.Errorf("failed to expect prompt: %s", err)
+	}
+	e.Send("y\n")
+
+	// the output ends without a match, the command's error is returned
+	_, err = e.Expect(ctx, regexp.MustCompile(`never`))
+	var ee *ExpectError
+	if !errors.As(err, &ee) || string(ee.Output) != "done\n" {
+		t.Errorf("failed, expected an ExpectError with the remaining output, got %v", err)
+	}
+	var xe *exec.ExitError
+	if !errors.As(err, &xe) || xe.ExitCode() != 2 {
+		t.Errorf("failed, the command was supposed to return error 2, got %v", err)
+	}
+
+	if !strings.Contains(log.String(), `Name: [send "world\n"]Hello world`) {
+		t.Errorf("invalid transcript: %q", log)
+	}
+}
+
+func TestExpectTimeout(t *testing.T) {
+	p, err := RunPty("/bin/sh", "-c", expectScript)
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	defer p.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = p.Expecter().Expect(ctx, regexp.MustCompile(`Password: `))
+	var ee *ExpectError
+	if !errors.As(err, &ee) || string(ee.Output) != "Name: " {
+		t.Errorf("failed, expected an ExpectError with the unmatched output, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("failed, expected a deadline error, got %v", err)
+	}
+}
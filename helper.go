@@ -0,0 +1,7 @@
+//go:build !linux
+
+package runutil
+
+func (x *execution) setupHelper() error {
+	return ErrNotSupported
+}
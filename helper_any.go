@@ -0,0 +1,19 @@
+package runutil
+
+// helperConfig is passed to the helper, which is the current executable started
+// again to set up the command in ways os/exec can't, before running it
+type helperConfig struct {
+	Path    string   // path of the command, looked up by the helper if it has no /
+	Dir     string   `json:",omitempty"` // directory of the command in the sandbox
+	RLimits []RLimit `json:",omitempty"`
+	Sandbox *Sandbox `json:",omitempty"`
+	Pipe    uint64   // inode of the pipe passed as helperFd
+}
+
+// useHelper has the command started through the helper, and returns its config
+func (x *execution) useHelper() *helperConfig {
+	if x.helper == nil {
+		x.helper = &helperConfig{}
+	}
+	return x.helper
+}
@@ -0,0 +1,121 @@
+package runutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// helperEnv is set in the environment of the helper, and holds its helperConfig
+const helperEnv = "_RUNUTIL_HELPER"
+
+// helperFd is the descriptor on which the helper reports setup errors
+const helperFd = 3
+
+func init() {
+	if data, ok := os.LookupEnv(helperEnv); ok {
+		helperMain(data)
+	}
+}
+
+// setupHelper starts the command through the helper, which receives its config in
+// the environment and reports errors on a pipe
+func (x *execution) setupHelper() error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(w.Fd()), &st); err != nil {
+		r.Close()
+		w.Close()
+		return err
+	}
+
+	x.helper.Path = x.cmd.Path
+	x.helper.Pipe = st.Ino
+	cfg, err := json.Marshal(x.helper)
+	if err != nil {
+		r.Close()
+		w.Close()
+		return err
+	}
+
+	env := x.cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	x.cmd.Env = append(env[:len(env):len(env)], helperEnv+"="+string(cfg))
+	x.cmd.Path = "/proc/self/exe"
+	x.cmd.ExtraFiles = []*os.File{w} // helperFd
+
+	x.started = append(x.started, func() error {
+		// the helper closes its end when running the command, or reports why it failed
+		w.Close()
+		msg, _ := io.ReadAll(r)
+		r.Close()
+		if len(msg) > 0 {
+			return errors.New(string(msg))
+		}
+		return nil
+	})
+	x.exit = append(x.exit, func(err error) error {
+		// in case the command failed to start
+		w.Close()
+		r.Close()
+		return err
+	})
+	return nil
+}
+
+// helperMain runs in the helper, and either runs the command or exits. If the
+// variable wasn't set by runutil, helperFd isn't its pipe and it returns.
+func helperMain(data string) {
+	var cfg helperConfig
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		return
+	}
+	var st syscall.Stat_t
+	if err := syscall.Fstat(helperFd, &st); err != nil || st.Mode&syscall.S_IFMT != syscall.S_IFIFO || st.Ino != cfg.Pipe {
+		return
+	}
+
+	os.Unsetenv(helperEnv)
+	err := helperExec(&cfg)
+
+	f := os.NewFile(helperFd, "helper")
+	fmt.Fprintf(f, "%s", err)
+	os.Exit(125)
+}
+
+// helperExec sets up the process and runs the command. It only returns on error.
+func helperExec(cfg *helperConfig) error {
+	path := cfg.Path
+	if cfg.Sandbox != nil {
+		if err := sandboxSetup(cfg.Sandbox, cfg.Dir); err != nil {
+			return fmt.Errorf("sandbox: %w", err)
+		}
+		if !strings.Contains(path, "/") {
+			var err error
+			if path, err = exec.LookPath(path); err != nil {
+				return fmt.Errorf("sandbox: %w", err)
+			}
+		}
+	}
+
+	// set last as they also apply to the helper
+	for _, l := range cfg.RLimits {
+		if err := syscall.Setrlimit(l.Resource, &syscall.Rlimit{Cur: l.Soft, Max: l.Hard}); err != nil {
+			return fmt.Errorf("rlimit: failed to set limit %d: %w", l.Resource, err)
+		}
+	}
+
+	syscall.CloseOnExec(helperFd)
+	err := syscall.Exec(path, os.Args, os.Environ())
+	return fmt.Errorf("failed to run %s: %w", path, err)
+}
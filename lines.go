@@ -0,0 +1,181 @@
+package runutil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"iter"
+	"os"
+	"sync"
+)
+
+// MaxLineLength is the default maximum length of the lines returned by RunLines and
+// Pipe.Lines, longer lines being split in chunks of this size. It is read when a
+// command is prepared, so it should only be changed during initialization. Use
+// Command.LineLength for a different length.
+var MaxLineLength = 64 * 1024
+
+// RunLines executes the command and calls onStdout and onStderr for each line it
+// writes to stdout and stderr, without the line ending. Calls are never made
+// concurrently. If onStdout is nil the output is discarded, and if onStderr is nil
+// stderr goes to os.Stderr. It returns once the command completed, with its error.
+func RunLines(ctx context.Context, onStdout, onStderr func(line string), arg ...string) error {
+	return Cmd(arg...).Context(ctx).RunLines(onStdout, onStderr)
+}
+
+// RunLines executes the command and calls onStdout and onStderr for each line it
+// writes to stdout and stderr, without the line ending. Calls are never made
+// concurrently. If onStdout is nil the output is discarded, and if onStderr is nil
+// stderr goes to os.Stderr. It returns once the command completed, with its error.
+func (e Env) RunLines(ctx context.Context, onStdout, onStderr func(line string), arg ...string) error {
+	return Cmd(arg...).Env(e).Context(ctx).RunLines(onStdout, onStderr)
+}
+
+// RunLines executes the command and calls onStdout and onStderr for each line it
+// writes to stdout and stderr, without the line ending. Calls are never made
+// concurrently. If onStdout is nil the output is discarded, and if onStderr is nil
+// stderr goes to the Command's Stderr, or os.Stderr. It returns once the command
+// completed, with its error.
+func (c *Command) RunLines(onStdout, onStderr func(line string)) error {
+	x, err := c.prepare()
+	if err != nil {
+		return err
+	}
+
+	lk := &sync.Mutex{}
+	stdout := &lineWriter{lk: lk, f: onStdout, max: x.lineMax}
+	x.cmd.Stdout = stdout
+	var stderr *lineWriter
+	if onStderr != nil {
+		stderr = &lineWriter{lk: lk, f: onStderr, max: x.lineMax}
+		x.cmd.Stderr = stderr
+		if x.stderr != nil {
+			// keep the tail for CaptureStderr
+			x.cmd.Stderr = io.MultiWriter(stderr, x.stderr)
+		}
+	} else if x.cmd.Stderr == nil {
+		x.cmd.Stderr = os.Stderr
+	}
+
+	if err = x.startCmd(); err != nil {
+		err = x.finish(err)
+	} else {
+		err = x.wait()
+	}
+
+	// send any last line not ending with a newline
+	stdout.flush()
+	if stderr != nil {
+		stderr.flush()
+	}
+	return err
+}
+
+// lineWriter calls f for each line written to it
+type lineWriter struct {
+	lk  *sync.Mutex // shared between stdout and stderr
+	f   func(line string)
+	buf []byte
+	max int
+
+	split bool // a chunk of max bytes was sent and the line may end right after it
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	if w.f == nil {
+		return len(p), nil
+	}
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i == -1 {
+			w.buf = append(w.buf, p...)
+			for len(w.buf) >= w.max {
+				w.f(string(w.buf[:w.max]))
+				w.buf = w.buf[w.max:]
+				w.split = true
+			}
+			break
+		}
+		w.buf = append(w.buf, p[:i]...)
+		p = p[i+1:]
+		w.emit()
+	}
+	return n, nil
+}
+
+// emit sends the buffered line, split if too long
+func (w *lineWriter) emit() {
+	line := dropCR(w.buf)
+	w.buf = w.buf[:0]
+	if w.split && len(line) == 0 {
+		// the line was exactly a multiple of max and was already sent
+		w.split = false
+		return
+	}
+	w.split = false
+	for len(line) > w.max {
+		w.f(string(line[:w.max]))
+		line = line[w.max:]
+	}
+	w.f(string(line))
+}
+
+func (w *lineWriter) flush() {
+	if w.f == nil || len(w.buf) == 0 {
+		return
+	}
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	w.emit()
+}
+
+// readLines returns an iterator over the lines of r, without their line ending.
+// Once r is over, a read error other than io.EOF is returned as the final value.
+func readLines(r io.Reader, max int) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		br := bufio.NewReaderSize(r, max)
+		split := false // the previous value was a chunk of a longer line
+		for {
+			line, err := br.ReadSlice('\n')
+			switch err {
+			case nil:
+				line = dropCR(line[:len(line)-1])
+				if split && len(line) == 0 {
+					// end of a line exactly as long as the buffer
+					split = false
+					continue
+				}
+				split = false
+				if !yield(string(line), nil) {
+					return
+				}
+			case bufio.ErrBufferFull:
+				split = true
+				if !yield(string(line), nil) {
+					return
+				}
+			default:
+				if len(line) > 0 && !yield(string(line), nil) {
+					return
+				}
+				if err != io.EOF {
+					yield("", err)
+				}
+				return
+			}
+		}
+	}
+}
+
+// dropCR removes a terminal \r, as bufio.ScanLines does
+func dropCR(b []byte) []byte {
+	if len(b) > 0 && b[len(b)-1] == '\r' {
+		return b[:len(b)-1]
+	}
+	return b
+}
@@ -0,0 +1,68 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestRunLines(t *testing.T) {
+	var out, errs []string
+	err := RunLines(context.Background(),
+		func(l string) { out = append(out, l) },
+		func(l string) { errs = append(errs, l) },
+		"/bin/sh", "-c", "echo one; echo oops >&2; printf 'two\\r\\nthree'; exit 4")
+
+	if strings.Join(out, "|") != "one|two|three" {
+		t.Errorf("invalid stdout lines: %q", out)
+	}
+	if strings.Join(errs, "|") != "oops" {
+		t.Errorf("invalid stderr lines: %q", errs)
+	}
+	var e *exec.ExitError
+	if !errors.As(err, &e) || e.ExitCode() != 4 {
+		t.Errorf("failed, the command was supposed to return error 4, got %v", err)
+	}
+}
+
+func TestPipeLines(t *testing.T) {
+	r, err := Cmd("/bin/sh", "-c", "echo first; echo 0123456789abcdefXYZ; echo 0123456789abcdef; echo; echo last; exit 5").LineLength(16).Read()
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	defer r.Close()
+
+	var lines []string
+	var final error
+	for l, err := range r.Lines() {
+		if err != nil {
+			final = err
+			break
+		}
+		lines = append(lines, l)
+	}
+	if strings.Join(lines, "|") != "first|0123456789abcdef|XYZ|0123456789abcdef||last" {
+		t.Errorf("invalid lines: %q", lines)
+	}
+	var e *exec.ExitError
+	if !errors.As(final, &e) || e.ExitCode() != 5 {
+		t.Errorf("failed, the command was supposed to return error 5, got %v", final)
+	}
+
+	// long lines are split by RunLines too
+	lines = nil
+	Cmd("echo", "0123456789abcdef0123456789abcdef!").LineLength(16).RunLines(func(l string) { lines = append(lines, l) }, nil)
+	if strings.Join(lines, "|") != "0123456789abcdef|0123456789abcdef|!" {
+		t.Errorf("invalid lines: %q", lines)
+	}
+
+	// a line of exactly LineLength doesn't end with an empty line, even if its
+	// newline is written separately
+	lines = nil
+	Cmd("/bin/sh", "-c", "printf 0123456789abcdef; sleep 0.1; echo; echo; echo x").LineLength(16).RunLines(func(l string) { lines = append(lines, l) }, nil)
+	if strings.Join(lines, "|") != "0123456789abcdef||x" {
+		t.Errorf("invalid lines: %q", lines)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package runutil
+
+// MatchUID matches processes running with the given effective uid, similar to pgrep -u
+func MatchUID(uid int) Matcher {
+	return MatcherFunc(func(p *Proc) bool {
+		return false
+	})
+}
+
+// MatchSession matches processes part of the given session, similar to pgrep -s
+func MatchSession(sid int) Matcher {
+	return MatcherFunc(func(p *Proc) bool {
+		return false
+	})
+}
+
+// MatchCgroup matches processes in the given cgroup or any of its descendants
+func MatchCgroup(path string) Matcher {
+	return MatcherFunc(func(p *Proc) bool {
+		return false
+	})
+}
@@ -0,0 +1,116 @@
+package runutil
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Matcher selects processes for FindProcesses
+type Matcher interface {
+	Match(p *Proc) bool
+}
+
+// MatcherFunc allows using a simple function as a Matcher
+type MatcherFunc func(p *Proc) bool
+
+func (f MatcherFunc) Match(p *Proc) bool {
+	return f(p)
+}
+
+// FindProcesses returns the processes matching m, sorted by pid. Similar to pgrep,
+// the current process is never part of the results.
+func FindProcesses(m Matcher) ([]*Proc, error) {
+	tbl, err := Processes()
+	if err != nil {
+		return nil, err
+	}
+
+	self := os.Getpid()
+	var res []*Proc
+	for pid, p := range tbl {
+		if pid != self && m.Match(p) {
+			res = append(res, p)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Pid < res[j].Pid })
+	return res, nil
+}
+
+// And matches processes matching all the given matchers
+func And(m ...Matcher) Matcher {
+	return MatcherFunc(func(p *Proc) bool {
+		for _, v := range m {
+			if !v.Match(p) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Or matches processes matching any of the given matchers
+func Or(m ...Matcher) Matcher {
+	return MatcherFunc(func(p *Proc) bool {
+		for _, v := range m {
+			if v.Match(p) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Not matches processes not matching m
+func Not(m Matcher) Matcher {
+	return MatcherFunc(func(p *Proc) bool {
+		return !m.Match(p)
+	})
+}
+
+// MatchComm matches processes whose name is exactly name. Note that on linux the
+// name is truncated to 15 characters.
+func MatchComm(name string) Matcher {
+	return MatcherFunc(func(p *Proc) bool {
+		return p.Name == name
+	})
+}
+
+// MatchCommRegexp matches processes whose name matches re
+func MatchCommRegexp(re *regexp.Regexp) Matcher {
+	return MatcherFunc(func(p *Proc) bool {
+		return re.MatchString(p.Name)
+	})
+}
+
+// MatchArgs matches processes whose full command line, with arguments separated by
+// spaces, contains s. This is similar to pgrep -f.
+func MatchArgs(s string) Matcher {
+	return MatcherFunc(func(p *Proc) bool {
+		return len(p.Cmdline) > 0 && strings.Contains(strings.Join(p.Cmdline, " "), s)
+	})
+}
+
+// MatchArgsRegexp matches processes whose full command line, with arguments separated
+// by spaces, matches re
+func MatchArgsRegexp(re *regexp.Regexp) Matcher {
+	return MatcherFunc(func(p *Proc) bool {
+		return len(p.Cmdline) > 0 && re.MatchString(strings.Join(p.Cmdline, " "))
+	})
+}
+
+// MatchExe matches processes running the executable at the given path, including
+// if the file was deleted or replaced since the process started.
+func MatchExe(path string) Matcher {
+	return MatcherFunc(func(p *Proc) bool {
+		return p.Exe != "" && strings.TrimSuffix(p.Exe, " (deleted)") == path
+	})
+}
+
+// MatchParent matches processes whose parent is ppid, similar to pgrep -P
+func MatchParent(ppid int) Matcher {
+	return MatcherFunc(func(p *Proc) bool {
+		return p.PPid == ppid
+	})
+}
@@ -0,0 +1,56 @@
+package runutil
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MatchUID matches processes running with the given effective uid, similar to pgrep -u
+func MatchUID(uid int) Matcher {
+	return MatcherFunc(func(p *Proc) bool {
+		st, err := LinuxPidStatus(uint64(p.Pid))
+		if err != nil {
+			return false
+		}
+		return st.Uid[1] == uid
+	})
+}
+
+// MatchSession matches processes part of the given session, similar to pgrep -s
+func MatchSession(sid int) Matcher {
+	return MatcherFunc(func(p *Proc) bool {
+		st, ok := p.State.(*LinuxProcState)
+		return ok && st.Session == sid
+	})
+}
+
+// MatchCgroup matches processes in the given cgroup or any of its descendants. The
+// path is relative to the cgroup root, such as "/system.slice/sshd.service". On
+// hosts with cgroup v1 controllers, the path of any hierarchy can match.
+func MatchCgroup(path string) Matcher {
+	path = filepath.Clean(path)
+	return MatcherFunc(func(p *Proc) bool {
+		buf, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(p.Pid), "cgroup"))
+		if err != nil {
+			return false
+		}
+
+		// lines are made of hierarchy-ID:controller-list:cgroup-path
+		s := bufio.NewScanner(bytes.NewReader(buf))
+		for s.Scan() {
+			parts := strings.SplitN(s.Text(), ":", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			cg := parts[2]
+			if cg == path || path == "/" || strings.HasPrefix(cg, path+"/") {
+				return true
+			}
+		}
+		return false
+	})
+}
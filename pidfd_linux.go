@@ -0,0 +1,31 @@
+package runutil
+
+import (
+	"syscall"
+)
+
+const (
+	// these syscalls were added after the numbering was unified, and have the same
+	// number on all architectures
+	_SYS_PIDFD_SEND_SIGNAL = 424
+	_SYS_PIDFD_OPEN        = 434
+)
+
+// pidfdOpen returns a file descriptor referring to the process pid. As long as the
+// descriptor is open, it refers to the same process even if the pid gets reused.
+func pidfdOpen(pid int) (int, error) {
+	fd, _, errno := syscall.Syscall(_SYS_PIDFD_OPEN, uintptr(pid), 0, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	syscall.CloseOnExec(int(fd))
+	return int(fd), nil
+}
+
+func pidfdSendSignal(fd int, sig syscall.Signal) error {
+	_, _, errno := syscall.Syscall6(_SYS_PIDFD_SEND_SIGNAL, uintptr(fd), uintptr(sig), 0, 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
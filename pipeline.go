@@ -0,0 +1,452 @@
+package runutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+)
+
+// Stage is a single step of a Pipeline, either an external command or a go function
+type Stage struct {
+	cmd *Command
+	fn  func(io.Reader, io.Writer) error
+}
+
+// CmdStage returns a Stage running the given command
+func CmdStage(arg ...string) Stage {
+	return Stage{cmd: Cmd(arg...).Dir("/")}
+}
+
+// CommandStage returns a Stage running c. Its stdin and stdout are connected to
+// the previous and next stages, and any value set for these is ignored.
+func CommandStage(c *Command) Stage {
+	return Stage{cmd: c}
+}
+
+// FuncStage returns a Stage running f in a goroutine. f receives the output of the
+// previous stage as its input, and anything written to the writer is passed to the
+// next stage. The writer is closed once f returns.
+func FuncStage(f func(io.Reader, io.Writer) error) Stage {
+	return Stage{fn: f}
+}
+
+func (s Stage) name() string {
+	if s.fn != nil {
+		return "func"
+	}
+	if len(s.cmd.args) == 0 {
+		return ""
+	}
+	return s.cmd.args[0]
+}
+
+// Pipeline is an ordered list of stages connected together the same way a shell
+// would do with a | b | c
+type Pipeline []Stage
+
+// NewPipeline returns a Pipeline made of the given stages
+func NewPipeline(stages ...Stage) Pipeline {
+	return Pipeline(stages)
+}
+
+// StageError is returned for each stage of a pipeline that failed
+type StageError struct {
+	Index int    // position of the stage in the pipeline, starting at 0
+	Name  string // command name, or "func" for go stages
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("stage %d (%s): %s", e.Index, e.Name, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// PipelineError is returned by a pipeline when any of its stages failed, similar to
+// bash's pipefail option. It lists all the failed stages in order.
+type PipelineError struct {
+	Stages []*StageError
+}
+
+func (e *PipelineError) Error() string {
+	msg := make([]string, 0, len(e.Stages))
+	for _, s := range e.Stages {
+		msg = append(msg, s.Error())
+	}
+	return "pipeline failed: " + strings.Join(msg, ", ")
+}
+
+// Unwrap allows errors.Is and errors.As to reach the errors of any failed stage
+func (e *PipelineError) Unwrap() []error {
+	res := make([]error, 0, len(e.Stages))
+	for _, s := range e.Stages {
+		res = append(res, s)
+	}
+	return res
+}
+
+type pipelineStage struct {
+	idx  int
+	name string
+	x    *execution // nil for go stages
+	done chan struct{}
+	err  error
+}
+
+type pipelinePipe struct {
+	r      *os.File // output of the last stage
+	stages []*pipelineStage
+	e      error       // set by wait, only read once o is done
+	o      sync.Once   // runs wait
+	closed atomic.Bool // set when the output was closed by the reader before EOF
+
+	lk    sync.Mutex
+	state pipeState
+}
+
+// Start starts all the stages of the pipeline, feeding r to the first one, and
+// returns the output of the last one. Errors of any stage are returned by the
+// final Read once the end of the output has been reached.
+func (p Pipeline) Start(r io.Reader) (Pipe, error) {
+	if len(p) == 0 {
+		return nil, ErrCommandMissing
+	}
+
+	res := &pipelinePipe{}
+	in := r
+	var inFile *os.File // pipe created by us feeding the current stage
+
+	for n, s := range p {
+		st := &pipelineStage{idx: n, name: s.name(), done: make(chan struct{})}
+
+		var x *execution
+		if s.fn == nil {
+			var err error
+			x, err = s.cmd.prepare()
+			if err != nil {
+				res.abort(inFile)
+				return nil, err
+			}
+			x.ownGroup()
+		}
+
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			res.abort(inFile)
+			if x != nil {
+				// release what prepare allocated, such as a cgroup
+				return nil, x.finish(err)
+			}
+			return nil, err
+		}
+
+		if s.fn != nil {
+			if in == nil {
+				in = bytes.NewReader(nil)
+			}
+			go st.runFunc(s.fn, in, inFile, pw)
+		} else {
+			x.cmd.Stdin = in
+			x.cmd.Stdout = pw
+			if x.cmd.Stderr == nil {
+				x.cmd.Stderr = os.Stderr
+			}
+			err = x.startCmd()
+			// the child has its own copy of the pipe ends now
+			pw.Close()
+			if inFile != nil {
+				inFile.Close()
+			}
+			if err != nil {
+				pr.Close()
+				res.abort(nil)
+				return nil, x.finish(err)
+			}
+			st.x = x
+			go st.wait()
+		}
+
+		res.stages = append(res.stages, st)
+		in, inFile = pr, pr
+	}
+
+	res.r = inFile
+	return res, nil
+}
+
+func (st *pipelineStage) runFunc(f func(io.Reader, io.Writer) error, in io.Reader, inFile, out *os.File) {
+	defer close(st.done)
+	st.err = f(in, out)
+	out.Close()
+	if inFile != nil {
+		// let the previous stage know we're not reading anymore
+		inFile.Close()
+	}
+}
+
+func (st *pipelineStage) wait() {
+	defer close(st.done)
+	st.err = st.x.wait()
+}
+
+// abort is called when a stage fails to start, and terminates any stage already running
+func (p *pipelinePipe) abort(inFile *os.File) {
+	if inFile != nil {
+		inFile.Close()
+	}
+	p.kill()
+	go p.waitAll()
+}
+
+func (p *pipelinePipe) kill() {
+	for _, st := range p.stages {
+		if st.x != nil {
+			st.x.term.terminate(st.x.signal, st.done)
+		}
+	}
+}
+
+func (p *pipelinePipe) waitAll() {
+	for _, st := range p.stages {
+		<-st.done
+	}
+}
+
+func (p *pipelinePipe) wait() {
+	p.waitAll()
+
+	var errs []*StageError
+	closed := false
+	for _, st := range p.stages {
+		if st.err == nil {
+			continue
+		}
+		if p.closed.Load() && (isSigpipe(st.err) || errors.Is(st.err, syscall.EPIPE)) {
+			// expected, as we closed the pipe the stage was writing to
+			closed = true
+			continue
+		}
+		errs = append(errs, &StageError{Index: st.idx, Name: st.name, Err: st.err})
+	}
+	if len(errs) > 0 {
+		p.e = &PipelineError{Stages: errs}
+	} else if closed {
+		p.e = ErrClosedByReader
+	}
+}
+
+// result waits for all the stages to complete and returns the pipeline's error
+func (p *pipelinePipe) result() error {
+	p.o.Do(p.wait)
+	return p.e
+}
+
+// eof returns true if EOF was already reached
+func (p *pipelinePipe) eof() bool {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	return p.state == pipeEOF
+}
+
+// setEOF waits for the pipeline once EOF was reached, and returns its error
+func (p *pipelinePipe) setEOF() error {
+	err := p.result()
+
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	if p.state == pipeOpen {
+		p.state = pipeEOF
+	}
+	return err
+}
+
+func (p *pipelinePipe) Read(b []byte) (int, error) {
+	if p.eof() {
+		if e := p.result(); e != nil {
+			return 0, e
+		}
+	}
+	n, err := p.r.Read(b)
+
+	if err == io.EOF {
+		if e := p.setEOF(); e != nil {
+			return n, e
+		}
+	}
+	return n, err
+}
+
+func (p *pipelinePipe) CopyTo(w io.Writer) (int64, error) {
+	if p.eof() {
+		if e := p.result(); e != nil {
+			return 0, e
+		}
+	}
+
+	n, err := copyOut(w, p.r)
+	if err != nil {
+		return n, err
+	}
+
+	if e := p.setEOF(); e != nil {
+		return n, e
+	}
+	return n, nil
+}
+
+// WriteTo is the same as CopyTo, and allows io.Copy to use it
+func (p *pipelinePipe) WriteTo(w io.Writer) (int64, error) {
+	return p.CopyTo(w)
+}
+
+func (p *pipelinePipe) File() *os.File {
+	return p.r
+}
+
+func (p *pipelinePipe) handedOff() func() error {
+	// the command now reading the pipe may not read it all
+	p.markClosed()
+	p.r.Close()
+	return p.result
+}
+
+// last returns the execution of the last stage running a command, or nil
+func (p *pipelinePipe) last() *execution {
+	for i := len(p.stages) - 1; i >= 0; i-- {
+		if x := p.stages[i].x; x != nil {
+			return x
+		}
+	}
+	return nil
+}
+
+func (p *pipelinePipe) Pid() int {
+	if x := p.last(); x != nil {
+		return x.cmd.Process.Pid
+	}
+	return 0
+}
+
+// Wait waits for all the stages to complete, and returns the Result of the last
+// stage running a command, if any, and the error of the pipeline
+func (p *pipelinePipe) Wait() (*Result, error) {
+	err := p.result()
+	if x := p.last(); x != nil {
+		return x.result(), err
+	}
+	return nil, err
+}
+
+func (p *pipelinePipe) ExitCode() int {
+	if x := p.last(); x != nil {
+		return x.exitCode()
+	}
+	return -1
+}
+
+func (p *pipelinePipe) Lines() iter.Seq2[string, error] {
+	max := MaxLineLength
+	if x := p.last(); x != nil {
+		max = x.lineMax
+	}
+	return readLines(p, max)
+}
+
+// markClosed records that the reader closed the pipe before reaching EOF
+func (p *pipelinePipe) markClosed() {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	if p.state == pipeOpen {
+		p.state = pipeClosed
+		p.closed.Store(true)
+	}
+}
+
+// Kill kills all the stages running a command with SIGKILL, without closing the
+// pipe. It returns os.ErrProcessDone if they all already completed.
+func (p *pipelinePipe) Kill() error {
+	res := error(os.ErrProcessDone)
+	for _, st := range p.stages {
+		if st.x == nil {
+			continue
+		}
+		if err := st.x.kill(); err != os.ErrProcessDone && res == os.ErrProcessDone {
+			res = err
+		}
+	}
+	return res
+}
+
+func (p *pipelinePipe) Close() error {
+	p.markClosed()
+	err := p.r.Close()
+
+	// wait for the command stages in background, each being terminated once its
+	// CloseTimeout expired
+	for _, st := range p.stages {
+		if st.x == nil {
+			continue
+		}
+		go func(x *execution) {
+			ctx, cancel := context.WithTimeout(context.Background(), x.term.closeTimeout())
+			defer cancel()
+			x.waitContext(ctx)
+		}(st.x)
+	}
+
+	return err
+}
+
+func (p *pipelinePipe) CloseWait(ctx context.Context) error {
+	p.markClosed()
+	err := p.r.Close()
+
+	if e := p.waitContext(ctx); e != nil {
+		return e
+	}
+	return err
+}
+
+// waitContext waits for all the stages to complete and returns the pipeline's
+// error. If ctx is done first, the stages are terminated.
+func (p *pipelinePipe) waitContext(ctx context.Context) error {
+	w := make(chan struct{})
+	go func() {
+		p.o.Do(p.wait)
+		close(w)
+	}()
+
+	select {
+	case <-w:
+	case <-ctx.Done():
+		p.terminate()
+		// force wait after kill
+		<-w
+	}
+	return p.e
+}
+
+// terminate terminates the command stages which didn't complete yet, according
+// to their TermPolicy
+func (p *pipelinePipe) terminate() {
+	for _, st := range p.stages {
+		if st.x == nil {
+			continue
+		}
+		select {
+		case <-st.x.done:
+		default:
+			st.x.term.terminate(st.x.signal, st.x.done)
+		}
+	}
+}
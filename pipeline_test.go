@@ -0,0 +1,92 @@
+package runutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	buf := []byte("this string is going to go through gzip in go, then gunzip, then base64 twice")
+
+	gz := func(r io.Reader, w io.Writer) error {
+		gw := gzip.NewWriter(w)
+		if _, err := io.Copy(gw, r); err != nil {
+			return err
+		}
+		return gw.Close()
+	}
+
+	res, err := NewPipeline(FuncStage(gz), CmdStage("gunzip"), CmdStage("base64"), CmdStage("base64", "-d")).Start(bytes.NewReader(buf))
+	if err != nil {
+		t.Errorf("failed to run test: %s", err)
+		return
+	}
+
+	final, err := ioutil.ReadAll(res)
+	if err != nil {
+		t.Errorf("failed to run test: %s", err)
+		return
+	}
+
+	if !bytes.Equal(final, buf) {
+		t.Errorf("failed to run test: should have been equal, instead got %s", final)
+	}
+}
+
+func TestPipelineError(t *testing.T) {
+	res, err := NewPipeline(
+		CmdStage("/bin/sh", "-c", "echo -n this will echo something but then things will go wrong; exit 42"),
+		CmdStage("cat"),
+	).Start(nil)
+	if err != nil {
+		t.Errorf("failed to run test: %s", err)
+		return
+	}
+
+	buf, err := ioutil.ReadAll(res)
+
+	if string(buf) != "this will echo something but then things will go wrong" {
+		t.Errorf("failed, buf did not contain the expected stuff")
+	}
+
+	var se *StageError
+	if !errors.As(err, &se) {
+		t.Errorf("failed, the pipeline was supposed to return an error of type StageError, got %T (%v)", err, err)
+		return
+	}
+	if se.Index != 0 || se.Name != "/bin/sh" {
+		t.Errorf("failed, expected stage 0 (/bin/sh) to fail, got %s", se)
+	}
+
+	var e *exec.ExitError
+	if !errors.As(err, &e) {
+		t.Errorf("failed, the pipeline was supposed to return an error of type exec.ExitError, got %T (%s)", err, err)
+		return
+	}
+	if e.ProcessState.ExitCode() != 42 {
+		t.Errorf("failed, the command was supposed to return error 42")
+	}
+}
+
+func TestPipelineClose(t *testing.T) {
+	// Close uses the TermPolicy of the stages
+	p, err := NewPipeline(
+		CommandStage(Cmd("sleep", "10").Terminate(TermPolicy{CloseTimeout: 100 * time.Millisecond})),
+		CmdStage("cat"),
+	).Start(nil)
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	start := time.Now()
+	p.Close()
+	p.Wait()
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("failed, the pipeline was closed after %s", d)
+	}
+}
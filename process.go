@@ -0,0 +1,147 @@
+package runutil
+
+import (
+	"io"
+	"os"
+)
+
+// Process is a command running in background, with its input and outputs available
+// as streams, allowing interactive exchanges with it.
+type Process struct {
+	x      *execution
+	stdin  *os.File
+	stdout *processPipe
+	stderr *processPipe
+}
+
+// Start starts the given command in background and returns a Process connected to
+// its stdin, stdout and stderr.
+func Start(arg ...string) (*Process, error) {
+	return Cmd(arg...).Start()
+}
+
+// Start starts the given command in background and returns a Process connected to
+// its stdin, stdout and stderr.
+func (e Env) Start(arg ...string) (*Process, error) {
+	return Cmd(arg...).Env(e).Start()
+}
+
+// Start starts the command in background and returns a Process. Any of stdin, stdout
+// or stderr that was set on the Command is used as is, and the matching method of
+// Process will return nil.
+func (c *Command) Start() (*Process, error) {
+	x, err := c.prepare()
+	if err != nil {
+		return nil, err
+	}
+	x.ownGroup()
+
+	res := &Process{x: x}
+
+	// files passed to the child, to be closed on our side once it started
+	var child []*os.File
+	closeAll := func(files []*os.File) {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	var stdout, stderr *os.File
+
+	if x.cmd.Stdin == nil {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, x.finish(err)
+		}
+		x.cmd.Stdin = r
+		res.stdin = w
+		child = append(child, r)
+	}
+	if c.stdout != nil {
+		x.cmd.Stdout = c.stdout
+	} else {
+		r, w, err := os.Pipe()
+		if err != nil {
+			closeAll(child)
+			res.stdin.Close()
+			return nil, x.finish(err)
+		}
+		x.cmd.Stdout = w
+		stdout = r
+		child = append(child, w)
+	}
+	if x.cmd.Stderr == nil {
+		r, w, err := os.Pipe()
+		if err != nil {
+			closeAll(child)
+			closeAll([]*os.File{res.stdin, stdout})
+			return nil, x.finish(err)
+		}
+		x.cmd.Stderr = w
+		stderr = r
+		child = append(child, w)
+	}
+
+	err = x.startCmd()
+	closeAll(child)
+	if err != nil {
+		closeAll([]*os.File{res.stdin, stdout, stderr})
+		return nil, x.finish(err)
+	}
+
+	if stdout != nil {
+		res.stdout = newProcessPipe(stdout, x)
+	}
+	if stderr != nil {
+		res.stderr = newProcessPipe(stderr, x)
+	}
+
+	return res, nil
+}
+
+// Stdin returns a writer connected to the process' input. Close it to signal the
+// end of the input to the process.
+func (p *Process) Stdin() io.WriteCloser {
+	if p.stdin == nil {
+		return nil
+	}
+	return p.stdin
+}
+
+// Stdout returns the output of the process. Once EOF is reached, the final Read will
+// return the process' error, if any.
+func (p *Process) Stdout() Pipe {
+	if p.stdout == nil {
+		return nil
+	}
+	return p.stdout
+}
+
+// Stderr returns the error output of the process. Once EOF is reached, the final Read
+// will return the process' error, if any.
+func (p *Process) Stderr() Pipe {
+	if p.stderr == nil {
+		return nil
+	}
+	return p.stderr
+}
+
+// Pid returns the process id of the process
+func (p *Process) Pid() int {
+	return p.x.cmd.Process.Pid
+}
+
+// Signal sends a signal to the process
+func (p *Process) Signal(sig os.Signal) error {
+	return p.x.cmd.Process.Signal(sig)
+}
+
+// Wait waits for the process to exit and returns its error, if any. Its stdin is
+// closed, but stdout and stderr can still be read for any data left in them.
+func (p *Process) Wait() error {
+	err := p.x.wait()
+	if p.stdin != nil {
+		p.stdin.Close()
+	}
+	return err
+}
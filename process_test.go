@@ -0,0 +1,50 @@
+package runutil
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+)
+
+func TestProcess(t *testing.T) {
+	p, err := Start("/bin/sh", "-c", "while read a b; do echo $((a+b)); done; echo done >&2; exit 42")
+	if err != nil {
+		t.Errorf("failed to run test: %s", err)
+		return
+	}
+
+	out := bufio.NewReader(p.Stdout())
+	for _, v := range [][2]string{{"1 2", "3"}, {"40 2", "42"}} {
+		if _, err := io.WriteString(p.Stdin(), v[0]+"\n"); err != nil {
+			t.Errorf("failed to write: %s", err)
+			return
+		}
+		line, err := out.ReadString('\n')
+		if err != nil {
+			t.Errorf("failed to read: %s", err)
+			return
+		}
+		if line != v[1]+"\n" {
+			t.Errorf("invalid output for %s, expected %s, got %s", v[0], v[1], line)
+		}
+	}
+	p.Stdin().Close()
+
+	// stderr should be readable to the end, with the error reported at EOF
+	buf, err := ioutil.ReadAll(p.Stderr())
+	if string(buf) != "done\n" {
+		t.Errorf("invalid stderr output: %s", buf)
+	}
+
+	var e *exec.ExitError
+	if !errors.As(err, &e) || e.ProcessState.ExitCode() != 42 {
+		t.Errorf("failed, the command was supposed to return error 42, got %v", err)
+	}
+
+	if err := p.Wait(); !errors.As(err, &e) {
+		t.Errorf("failed, Wait was supposed to return the same error, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+//go:build !linux
+
+package runutil
+
+import (
+	"context"
+	"syscall"
+)
+
+// ProcessHandle refers to a running process. It is only supported on linux.
+type ProcessHandle struct {
+	Pid int
+}
+
+// OpenProcess returns a handle to the process pid
+func OpenProcess(pid int) (*ProcessHandle, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *ProcessHandle) State() (ProcState, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *ProcessHandle) Signal(sig syscall.Signal) error {
+	return ErrNotSupported
+}
+
+func (h *ProcessHandle) Wait(ctx context.Context) error {
+	return ErrNotSupported
+}
+
+func (h *ProcessHandle) Close() error {
+	return nil
+}
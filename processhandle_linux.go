@@ -0,0 +1,195 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// ProcessHandle refers to a running process, which doesn't need to be a child of the
+// current process. On kernels supporting pidfd, it is guaranteed to refer to the same
+// process even if its pid gets reused after it exits. Other kernels fall back to
+// polling /proc and checking the start time of the process.
+type ProcessHandle struct {
+	Pid       int
+	startTime uint64
+	f         *os.File // pidfd, nil if not supported
+}
+
+// OpenProcess returns a handle to the process pid. The handle should be closed once
+// not needed anymore.
+func OpenProcess(pid int) (*ProcessHandle, error) {
+	st, err := LinuxPidState(uint64(pid))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, os.ErrProcessDone
+		}
+		return nil, err
+	}
+	return openProcess(pid, st.StartTime)
+}
+
+// openProcess opens a handle to pid, making sure it still has the given start time
+func openProcess(pid int, startTime uint64) (*ProcessHandle, error) {
+	h := &ProcessHandle{Pid: pid, startTime: startTime}
+
+	fd, err := pidfdOpen(pid)
+	switch err {
+	case nil:
+		// make it non blocking so we can use go's poller to wait on it
+		syscall.SetNonblock(fd, true)
+		h.f = os.NewFile(uintptr(fd), "pidfd")
+	case syscall.ENOSYS:
+		// not supported by this kernel
+	case syscall.ESRCH:
+		return nil, os.ErrProcessDone
+	default:
+		return nil, err
+	}
+
+	// the pidfd was opened before this check, so if the process at pid still has the
+	// same start time, the pidfd refers to it and signals sent through it can't
+	// reach another process.
+	if _, err := h.state(); err != nil {
+		h.Close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// state returns the state of the process, or an error if it isn't the same process anymore
+func (h *ProcessHandle) state() (*LinuxProcState, error) {
+	st, err := LinuxPidState(uint64(h.Pid))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, os.ErrProcessDone
+		}
+		return nil, err
+	}
+	if st.StartTime != h.startTime {
+		return nil, ErrProcessChanged
+	}
+	return st, nil
+}
+
+// State returns the current state of the process, or os.ErrProcessDone if it exited
+// and was reaped. If the pid was reused, ErrProcessChanged is returned.
+func (h *ProcessHandle) State() (ProcState, error) {
+	st, err := h.state()
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// Signal sends sig to the process
+func (h *ProcessHandle) Signal(sig syscall.Signal) error {
+	var err error
+	if h.f != nil {
+		err = pidfdSendSignal(int(h.f.Fd()), sig)
+	} else {
+		// there is a small window between this check and the signal
+		if _, err = h.state(); err != nil {
+			return err
+		}
+		err = syscall.Kill(h.Pid, sig)
+	}
+	if err == syscall.ESRCH {
+		return os.ErrProcessDone
+	}
+	return err
+}
+
+// Wait waits for the process to exit, or for ctx to be done in which case ctx.Err()
+// is returned. As the process may not be a child of the current process, its exit
+// status is not available.
+func (h *ProcessHandle) Wait(ctx context.Context) error {
+	if h.f == nil {
+		return h.pollWait(ctx)
+	}
+
+	rc, err := h.f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	// interrupt the wait by setting a deadline if ctx is done first
+	h.f.SetReadDeadline(time.Time{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			h.f.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	// the pidfd becomes readable once the process exits
+	err = rc.Read(func(fd uintptr) bool {
+		return pollIn(int(fd))
+	})
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// pollWait checks the state of the process regularly until it exits
+func (h *ProcessHandle) pollWait(ctx context.Context) error {
+	t := time.NewTicker(50 * time.Millisecond)
+	defer t.Stop()
+
+	for {
+		st, err := h.state()
+		if err != nil {
+			if errors.Is(err, os.ErrProcessDone) || errors.Is(err, ErrProcessChanged) {
+				return nil
+			}
+			return err
+		}
+		if !st.Alive() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Close releases the resources held by the handle
+func (h *ProcessHandle) Close() error {
+	if h.f == nil {
+		return nil
+	}
+	return h.f.Close()
+}
+
+const (
+	_POLLIN  = 0x1
+	_POLLOUT = 0x4
+)
+
+// pollIn returns true if fd is readable, without blocking
+func pollIn(fd int) bool {
+	return pollFd(fd, _POLLIN)
+}
+
+// pollFd returns true if any of events is ready on fd, without blocking
+func pollFd(fd int, events int16) bool {
+	pfd := struct {
+		fd      int32
+		events  int16
+		revents int16
+	}{fd: int32(fd), events: events}
+	ts := syscall.Timespec{}
+
+	n, _, errno := syscall.Syscall6(syscall.SYS_PPOLL, uintptr(unsafe.Pointer(&pfd)), 1, uintptr(unsafe.Pointer(&ts)), 0, 0, 0)
+	return errno == 0 && n > 0 && pfd.revents&events != 0
+}
@@ -3,102 +3,212 @@ package runutil
 import (
 	"context"
 	"io"
-	"os/exec"
+	"iter"
+	"os"
 	"sync"
-	"time"
 )
 
 type Pipe interface {
 	io.ReadCloser
 	CopyTo(io.Writer) (int64, error)
 	CloseWait(ctx context.Context) error
+
+	// Lines returns an iterator over the lines of the output, without their line
+	// ending. If the command fails, its error is returned as the final value.
+	// Lines longer than the command's LineLength are split.
+	Lines() iter.Seq2[string, error]
+
+	// File returns the file the output is read from, or nil if there is none. When a
+	// Pipe is used as the input of another command, this file is passed directly to
+	// it instead of copying the data.
+	File() *os.File
+
+	// Pid returns the process id of the command. For pipelines, this is the last
+	// stage running a command, or 0 if there is none.
+	Pid() int
+
+	// Wait waits for the command to complete and returns its Result and error. The
+	// output must have been read or closed, or the command may block writing it.
+	Wait() (*Result, error)
+
+	// ExitCode returns the exit code of the command, or -1 if it is still running
+	// or was killed by a signal
+	ExitCode() int
+
+	// Kill kills the command immediately, without closing the Pipe so its remaining
+	// output can still be read. It returns os.ErrProcessDone if the command already
+	// completed.
+	Kill() error
+}
+
+// fileHandoff is implemented by pipes whose file can be given to another command
+type fileHandoff interface {
+	File() *os.File
+
+	// handedOff is called once the file was passed to a running command, and
+	// returns a function waiting for the command writing to the pipe
+	handedOff() func() error
+}
+
+// copyOut copies r to w, using splice if possible
+func copyOut(w io.Writer, r io.Reader) (int64, error) {
+	if wf, ok := w.(*os.File); ok {
+		if rf, ok := r.(*os.File); ok {
+			if n, handled, err := spliceFile(wf, rf); handled {
+				return n, err
+			}
+		}
+	}
+	return io.Copy(w, r)
 }
 
+// processPipe is the output of a command. It can be used from multiple goroutines,
+// for example to Close or Kill it while a Read is in progress.
 type processPipe struct {
 	r io.ReadCloser
-	e error
-	o sync.Once
-	p *exec.Cmd
+	x *execution
+
+	lk    sync.Mutex
+	state pipeState
+	e     error // error of the command, once state is pipeEOF
+}
+
+type pipeState int
+
+const (
+	pipeOpen   pipeState = iota
+	pipeEOF              // the output was read until EOF
+	pipeClosed           // the output was closed by the reader before EOF
+)
+
+func newProcessPipe(r io.ReadCloser, x *execution) *processPipe {
+	return &processPipe{r: r, x: x}
 }
 
-func newProcessPipe(r io.ReadCloser, p *exec.Cmd) *processPipe {
-	return &processPipe{r: r, p: p}
+// eof returns true and the error of the command if EOF was already reached
+func (r *processPipe) eof() (bool, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	return r.state == pipeEOF, r.e
+}
+
+// setEOF waits for the command once EOF was reached, and returns its error
+func (r *processPipe) setEOF() error {
+	err := r.x.wait()
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	if r.state == pipeOpen {
+		r.state = pipeEOF
+		r.e = err
+	}
+	return err
 }
 
 func (r *processPipe) Read(p []byte) (int, error) {
-	if r.e != nil {
-		return 0, r.e
+	if ok, e := r.eof(); ok && e != nil {
+		return 0, e
 	}
 	n, err := r.r.Read(p)
 
 	if err == io.EOF {
 		// check if we received error after waiting for Wait()
-		r.o.Do(func() {
-			r.e = r.p.Wait()
-		})
-		if r.e != nil {
-			return n, r.e
+		if e := r.setEOF(); e != nil {
+			return n, e
 		}
 	}
 	return n, err
 }
 
+// markClosed records that the reader closed the pipe before reaching EOF
+func (r *processPipe) markClosed() {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	if r.state == pipeOpen {
+		r.state = pipeClosed
+		r.x.closed.Store(true)
+	}
+}
+
 func (r *processPipe) Close() error {
+	r.markClosed()
 	err := r.r.Close()
 
-	// call CloseWait() in background
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// wait for the command in background
+	ctx, cancel := context.WithTimeout(context.Background(), r.x.term.closeTimeout())
 	go func() {
 		defer cancel()
-		r.CloseWait(ctx)
+		r.x.waitContext(ctx)
 	}()
 
 	return err
 }
 
 func (r *processPipe) CopyTo(w io.Writer) (int64, error) {
-	if r.e != nil {
-		return 0, r.e
+	if ok, e := r.eof(); ok && e != nil {
+		return 0, e
 	}
 
 	// read whole pipe & write to writer
-	n, err := io.Copy(w, r.r)
+	n, err := copyOut(w, r.r)
 	if err != nil {
 		return n, err
 	}
 
 	// we reached eof
-	r.o.Do(func() {
-		r.e = r.p.Wait()
-	})
-	if r.e != nil {
-		return n, r.e
+	if e := r.setEOF(); e != nil {
+		return n, e
 	}
 	return n, nil
 }
 
-func (r *processPipe) CloseWait(ctx context.Context) error {
-	err := r.r.Close()
-	w := make(chan struct{})
+// WriteTo is the same as CopyTo, and allows io.Copy to use it
+func (r *processPipe) WriteTo(w io.Writer) (int64, error) {
+	return r.CopyTo(w)
+}
 
-	go func() {
-		r.o.Do(func() {
-			r.e = r.p.Wait()
-		})
-		w <- struct{}{}
-	}()
+func (r *processPipe) File() *os.File {
+	f, _ := r.r.(*os.File)
+	return f
+}
 
-	select {
-	case <-w:
-	case <-ctx.Done():
-		r.p.Process.Kill()
-		// force wait after kill
-		<-w
-	}
+func (r *processPipe) handedOff() func() error {
+	// the command now reading the pipe may not read it all
+	r.markClosed()
+	r.r.Close()
+	return r.x.wait
+}
 
-	if r.e != nil {
-		return r.e
-	}
+func (r *processPipe) Pid() int {
+	return r.x.cmd.Process.Pid
+}
 
+func (r *processPipe) Wait() (*Result, error) {
+	err := r.x.wait()
+	return r.x.result(), err
+}
+
+func (r *processPipe) ExitCode() int {
+	return r.x.exitCode()
+}
+
+// Kill kills the command and anything it spawned with SIGKILL, without closing the
+// pipe. What the command wrote before being killed can still be read, and the
+// final Read returns its error.
+func (r *processPipe) Kill() error {
+	return r.x.kill()
+}
+
+func (r *processPipe) Lines() iter.Seq2[string, error] {
+	return readLines(r, r.x.lineMax)
+}
+
+func (r *processPipe) CloseWait(ctx context.Context) error {
+	r.markClosed()
+	err := r.r.Close()
+
+	if e := r.x.waitContext(ctx); e != nil {
+		return e
+	}
 	return err
 }
@@ -0,0 +1,212 @@
+package runutil
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// checkGoroutines fails if goroutines started by the test are still running
+func checkGoroutines(t *testing.T, base int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > base {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Errorf("goroutines leaked, %d running instead of %d:\n%s", runtime.NumGoroutine(), base, buf[:runtime.Stack(buf, true)])
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPipeConcurrentClose(t *testing.T) {
+	base := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		r, err := RunRead("yes")
+		if err != nil {
+			t.Fatalf("failed to run test: %s", err)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 512)
+			for {
+				if _, err := r.Read(buf); err != nil {
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			r.CopyTo(io.Discard)
+		}()
+		go func() {
+			defer wg.Done()
+			time.Sleep(time.Millisecond)
+			r.Close()
+		}()
+		go func() {
+			defer wg.Done()
+			r.ExitCode()
+			r.Pid()
+		}()
+
+		// several CloseWait at once must all return the same error
+		errs := make([]error, 3)
+		for n := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[n] = r.CloseWait(context.Background())
+			}()
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			if !errors.Is(err, ErrClosedByReader) {
+				t.Errorf("failed, expected ErrClosedByReader, got %v", err)
+			}
+		}
+		if _, err := r.Wait(); !errors.Is(err, ErrClosedByReader) {
+			t.Errorf("failed, expected ErrClosedByReader from Wait, got %v", err)
+		}
+	}
+
+	checkGoroutines(t, base)
+}
+
+func TestPipeConcurrentEOF(t *testing.T) {
+	base := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		r, err := RunRead("/bin/sh", "-c", "echo hello; exit 2")
+		if err != nil {
+			t.Fatalf("failed to run test: %s", err)
+		}
+
+		// the command completes while being read and waited
+		var wg sync.WaitGroup
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			io.ReadAll(r)
+		}()
+		go func() {
+			defer wg.Done()
+			r.Wait()
+		}()
+		go func() {
+			defer wg.Done()
+			r.ExitCode()
+		}()
+		wg.Wait()
+
+		var e *exec.ExitError
+		if _, err := r.Wait(); !errors.As(err, &e) || e.ExitCode() != 2 {
+			t.Errorf("failed, expected exit status 2, got %v", err)
+		}
+		if r.ExitCode() != 2 {
+			t.Errorf("failed, expected exit code 2, got %d", r.ExitCode())
+		}
+		if err := r.CloseWait(context.Background()); !errors.As(err, &e) {
+			t.Errorf("failed, expected CloseWait to return the exit status, got %v", err)
+		}
+	}
+
+	checkGoroutines(t, base)
+}
+
+func TestPipeKill(t *testing.T) {
+	r, err := RunRead("/bin/sh", "-c", "echo hello; sleep 10")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+
+	buf := make([]byte, 6)
+	if _, err := io.ReadFull(r, buf); err != nil || string(buf) != "hello\n" {
+		t.Fatalf("failed to run test: %q %v", buf, err)
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Kill()
+		}()
+	}
+
+	// the pipe is still open, and returns the error of the command
+	_, err = io.ReadAll(r)
+	var e *exec.ExitError
+	if !errors.As(err, &e) || e.String() != "signal: killed" {
+		t.Errorf("failed, expected the command to be killed, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("failed, the command wasn't killed in time")
+	}
+	wg.Wait()
+
+	if err := r.Kill(); err != os.ErrProcessDone {
+		t.Errorf("failed, expected os.ErrProcessDone, got %v", err)
+	}
+	r.Close()
+}
+
+func TestPipelineConcurrentClose(t *testing.T) {
+	base := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		p, err := NewPipeline(CmdStage("yes"), CmdStage("cat")).Start(nil)
+		if err != nil {
+			t.Fatalf("failed to run test: %s", err)
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			io.Copy(io.Discard, p)
+		}()
+		go func() {
+			defer wg.Done()
+			time.Sleep(time.Millisecond)
+			p.Close()
+		}()
+		go func() {
+			defer wg.Done()
+			p.CloseWait(context.Background())
+		}()
+		go func() {
+			defer wg.Done()
+			p.ExitCode()
+		}()
+		wg.Wait()
+
+		if _, err := p.Wait(); err != ErrClosedByReader {
+			t.Errorf("failed, expected ErrClosedByReader, got %v", err)
+		}
+	}
+
+	// killed stages are reported
+	p, _ := NewPipeline(CmdStage("sleep", "10"), CmdStage("cat")).Start(nil)
+	if err := p.Kill(); err != nil {
+		t.Errorf("failed to kill pipeline: %s", err)
+	}
+	var pe *PipelineError
+	if _, err := io.ReadAll(p); !errors.As(err, &pe) || len(pe.Stages) != 2 {
+		t.Errorf("failed, expected both stages to fail, got %v", err)
+	}
+
+	checkGoroutines(t, base)
+}
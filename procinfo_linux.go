@@ -0,0 +1,429 @@
+package runutil
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LinuxProcInfo groups all the information available about a process under /proc/<pid>
+type LinuxProcInfo struct {
+	Stat   *LinuxProcState
+	Status *LinuxProcStatus
+	Statm  *LinuxProcStatm
+	IO     *LinuxProcIO    // nil if not allowed to read it
+	Limits LinuxProcLimits // nil if not allowed to read it
+}
+
+// LinuxProcStatus holds the values of /proc/<pid>/status. Memory values are converted to bytes.
+type LinuxProcStatus struct {
+	Name                     string
+	Umask                    uint32
+	State                    string // "S (sleeping)", etc
+	Tgid                     int
+	Ngid                     int
+	Pid                      int
+	PPid                     int
+	TracerPid                int
+	Uid                      [4]int // real, effective, saved set, filesystem
+	Gid                      [4]int // real, effective, saved set, filesystem
+	FDSize                   int
+	Groups                   []int // supplementary groups
+	NSpid                    []int // pid in each of the pid namespaces the process is in
+	VmPeak                   uint64
+	VmSize                   uint64
+	VmLck                    uint64
+	VmPin                    uint64
+	VmHWM                    uint64 // peak resident set size
+	VmRSS                    uint64
+	RssAnon                  uint64
+	RssFile                  uint64
+	RssShmem                 uint64
+	VmData                   uint64
+	VmStk                    uint64
+	VmExe                    uint64
+	VmLib                    uint64
+	VmPTE                    uint64
+	VmSwap                   uint64
+	Threads                  int
+	SigPnd                   uint64
+	ShdPnd                   uint64
+	SigBlk                   uint64
+	SigIgn                   uint64
+	SigCgt                   uint64
+	CapInh                   uint64
+	CapPrm                   uint64
+	CapEff                   uint64
+	CapBnd                   uint64
+	CapAmb                   uint64
+	NoNewPrivs               bool
+	Seccomp                  int // 0 disabled, 1 strict, 2 filter
+	VoluntaryCtxtSwitches    uint64
+	NonvoluntaryCtxtSwitches uint64
+}
+
+// LinuxProcStatm holds the values of /proc/<pid>/statm, in pages
+type LinuxProcStatm struct {
+	Size     uint64 // total program size
+	Resident uint64 // resident set size
+	Shared   uint64 // resident shared pages
+	Text     uint64 // text (code)
+	Lib      uint64 // unused since Linux 2.6
+	Data     uint64 // data + stack
+	Dt       uint64 // unused since Linux 2.6
+}
+
+// LinuxProcIO holds the values of /proc/<pid>/io
+type LinuxProcIO struct {
+	RChar               uint64 // bytes read through read(2) and similar
+	WChar               uint64 // bytes written through write(2) and similar
+	SyscR               uint64 // read syscalls count
+	SyscW               uint64 // write syscalls count
+	ReadBytes           uint64 // bytes fetched from the storage layer
+	WriteBytes          uint64 // bytes sent to the storage layer
+	CancelledWriteBytes uint64
+}
+
+// LinuxProcLimit is a single line of /proc/<pid>/limits
+type LinuxProcLimit struct {
+	Name string // "Max open files", etc
+	Soft uint64 // LimitUnlimited if unlimited
+	Hard uint64 // LimitUnlimited if unlimited
+	Unit string // "files", "bytes", etc. Can be empty
+}
+
+// LinuxProcLimits holds the values of /proc/<pid>/limits
+type LinuxProcLimits []LinuxProcLimit
+
+// Get returns the limit with the given name, such as "Max open files"
+func (l LinuxProcLimits) Get(name string) (LinuxProcLimit, bool) {
+	for _, v := range l {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return LinuxProcLimit{}, false
+}
+
+func procFile(pid uint64, name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join("/proc", strconv.FormatUint(pid, 10), name))
+}
+
+// LinuxPidInfo returns all the information available about the given pid. IO and
+// Limits are left nil if the current process isn't allowed to read them.
+func LinuxPidInfo(pid uint64) (*LinuxProcInfo, error) {
+	var err error
+	res := &LinuxProcInfo{}
+
+	if res.Stat, err = LinuxPidState(pid); err != nil {
+		return nil, err
+	}
+	if res.Status, err = LinuxPidStatus(pid); err != nil {
+		return nil, err
+	}
+	if res.Statm, err = LinuxPidStatm(pid); err != nil {
+		return nil, err
+	}
+	if res.IO, err = LinuxPidIO(pid); err != nil && !os.IsPermission(err) {
+		return nil, err
+	}
+	if res.Limits, err = LinuxPidLimits(pid); err != nil && !os.IsPermission(err) {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// LinuxPidStatus reads /proc/<pid>/status
+func LinuxPidStatus(pid uint64) (*LinuxProcStatus, error) {
+	buf, err := procFile(pid, "status")
+	if err != nil {
+		return nil, err
+	}
+
+	res := &LinuxProcStatus{}
+	return res, res.parse(string(buf))
+}
+
+// LinuxPidStatm reads /proc/<pid>/statm
+func LinuxPidStatm(pid uint64) (*LinuxProcStatm, error) {
+	buf, err := procFile(pid, "statm")
+	if err != nil {
+		return nil, err
+	}
+
+	res := &LinuxProcStatm{}
+	return res, res.parse(string(buf))
+}
+
+// LinuxPidIO reads /proc/<pid>/io, which is only allowed for processes we could ptrace
+func LinuxPidIO(pid uint64) (*LinuxProcIO, error) {
+	buf, err := procFile(pid, "io")
+	if err != nil {
+		return nil, err
+	}
+
+	res := &LinuxProcIO{}
+	return res, res.parse(string(buf))
+}
+
+// LinuxPidLimits reads /proc/<pid>/limits
+func LinuxPidLimits(pid uint64) (LinuxProcLimits, error) {
+	buf, err := procFile(pid, "limits")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLimits(string(buf))
+}
+
+// parseKeyValues parses files made of "Key: value" lines and calls f for each of them
+func parseKeyValues(data string, f func(k, v string) error) error {
+	s := bufio.NewScanner(strings.NewReader(data))
+	for s.Scan() {
+		k, v, found := strings.Cut(s.Text(), ":")
+		if !found {
+			continue
+		}
+		if err := f(k, strings.TrimSpace(v)); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", k, err)
+		}
+	}
+	return s.Err()
+}
+
+// parseKB parses values such as "1300 kB" into bytes
+func parseKB(v string, out *uint64) error {
+	v, unit, _ := strings.Cut(v, " ")
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return err
+	}
+	if unit == "kB" {
+		n *= 1024
+	}
+	*out = n
+	return nil
+}
+
+func parseHex(v string, out *uint64) (err error) {
+	*out, err = strconv.ParseUint(v, 16, 64)
+	return
+}
+
+func parseInts(v string) ([]int, error) {
+	var res []int
+	for _, s := range strings.Fields(v) {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+// parseIDs parses the real, effective, saved set and filesystem ids of Uid and Gid
+func parseIDs(v string, out []int) error {
+	ids, err := parseInts(v)
+	if err != nil {
+		return err
+	}
+	if len(ids) != len(out) {
+		return fmt.Errorf("expected %d values, got %d", len(out), len(ids))
+	}
+	copy(out, ids)
+	return nil
+}
+
+func (s *LinuxProcStatus) parse(data string) error {
+	return parseKeyValues(data, func(k, v string) error {
+		var err error
+		switch k {
+		case "Name":
+			s.Name = v
+		case "Umask":
+			var n uint64
+			n, err = strconv.ParseUint(v, 8, 32)
+			s.Umask = uint32(n)
+		case "State":
+			s.State = v
+		case "Tgid":
+			s.Tgid, err = strconv.Atoi(v)
+		case "Ngid":
+			s.Ngid, err = strconv.Atoi(v)
+		case "Pid":
+			s.Pid, err = strconv.Atoi(v)
+		case "PPid":
+			s.PPid, err = strconv.Atoi(v)
+		case "TracerPid":
+			s.TracerPid, err = strconv.Atoi(v)
+		case "Uid":
+			err = parseIDs(v, s.Uid[:])
+		case "Gid":
+			err = parseIDs(v, s.Gid[:])
+		case "FDSize":
+			s.FDSize, err = strconv.Atoi(v)
+		case "Groups":
+			s.Groups, err = parseInts(v)
+		case "NSpid":
+			s.NSpid, err = parseInts(v)
+		case "VmPeak":
+			err = parseKB(v, &s.VmPeak)
+		case "VmSize":
+			err = parseKB(v, &s.VmSize)
+		case "VmLck":
+			err = parseKB(v, &s.VmLck)
+		case "VmPin":
+			err = parseKB(v, &s.VmPin)
+		case "VmHWM":
+			err = parseKB(v, &s.VmHWM)
+		case "VmRSS":
+			err = parseKB(v, &s.VmRSS)
+		case "RssAnon":
+			err = parseKB(v, &s.RssAnon)
+		case "RssFile":
+			err = parseKB(v, &s.RssFile)
+		case "RssShmem":
+			err = parseKB(v, &s.RssShmem)
+		case "VmData":
+			err = parseKB(v, &s.VmData)
+		case "VmStk":
+			err = parseKB(v, &s.VmStk)
+		case "VmExe":
+			err = parseKB(v, &s.VmExe)
+		case "VmLib":
+			err = parseKB(v, &s.VmLib)
+		case "VmPTE":
+			err = parseKB(v, &s.VmPTE)
+		case "VmSwap":
+			err = parseKB(v, &s.VmSwap)
+		case "Threads":
+			s.Threads, err = strconv.Atoi(v)
+		case "SigPnd":
+			err = parseHex(v, &s.SigPnd)
+		case "ShdPnd":
+			err = parseHex(v, &s.ShdPnd)
+		case "SigBlk":
+			err = parseHex(v, &s.SigBlk)
+		case "SigIgn":
+			err = parseHex(v, &s.SigIgn)
+		case "SigCgt":
+			err = parseHex(v, &s.SigCgt)
+		case "CapInh":
+			err = parseHex(v, &s.CapInh)
+		case "CapPrm":
+			err = parseHex(v, &s.CapPrm)
+		case "CapEff":
+			err = parseHex(v, &s.CapEff)
+		case "CapBnd":
+			err = parseHex(v, &s.CapBnd)
+		case "CapAmb":
+			err = parseHex(v, &s.CapAmb)
+		case "NoNewPrivs":
+			s.NoNewPrivs = v == "1"
+		case "Seccomp":
+			s.Seccomp, err = strconv.Atoi(v)
+		case "voluntary_ctxt_switches":
+			s.VoluntaryCtxtSwitches, err = strconv.ParseUint(v, 10, 64)
+		case "nonvoluntary_ctxt_switches":
+			s.NonvoluntaryCtxtSwitches, err = strconv.ParseUint(v, 10, 64)
+		}
+		return err
+	})
+}
+
+func (s *LinuxProcStatm) parse(data string) error {
+	dec := strings.Fields(data)
+	fields := []any{&s.Size, &s.Resident, &s.Shared, &s.Text, &s.Lib, &s.Data, &s.Dt}
+
+	for n, f := range fields {
+		if err := eatValue(&dec, f); err != nil {
+			return fmt.Errorf("invalid statm field %d: %w", n+1, err)
+		}
+	}
+	return nil
+}
+
+func (s *LinuxProcIO) parse(data string) error {
+	return parseKeyValues(data, func(k, v string) error {
+		var out *uint64
+		switch k {
+		case "rchar":
+			out = &s.RChar
+		case "wchar":
+			out = &s.WChar
+		case "syscr":
+			out = &s.SyscR
+		case "syscw":
+			out = &s.SyscW
+		case "read_bytes":
+			out = &s.ReadBytes
+		case "write_bytes":
+			out = &s.WriteBytes
+		case "cancelled_write_bytes":
+			out = &s.CancelledWriteBytes
+		default:
+			return nil
+		}
+		var err error
+		*out, err = strconv.ParseUint(v, 10, 64)
+		return err
+	})
+}
+
+func parseLimitValue(v string) (uint64, error) {
+	if v == "unlimited" {
+		return LimitUnlimited, nil
+	}
+	return strconv.ParseUint(v, 10, 64)
+}
+
+func parseLimits(data string) (LinuxProcLimits, error) {
+	// Limit                     Soft Limit           Hard Limit           Units
+	// Max cpu time              unlimited            unlimited            seconds
+	// columns are aligned on the header, and names contain spaces
+	lines := strings.Split(data, "\n")
+	if len(lines) < 1 {
+		return nil, fmt.Errorf("invalid limits format: missing header")
+	}
+	hdr := lines[0]
+	softPos := strings.Index(hdr, "Soft Limit")
+	hardPos := strings.Index(hdr, "Hard Limit")
+	unitPos := strings.Index(hdr, "Units")
+	if softPos == -1 || hardPos < softPos || unitPos < hardPos {
+		return nil, fmt.Errorf("invalid limits format: bad header")
+	}
+
+	var res LinuxProcLimits
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(line) < hardPos {
+			return nil, fmt.Errorf("invalid limits line: %s", line)
+		}
+		l := LinuxProcLimit{Name: strings.TrimSpace(line[:softPos])}
+		values := strings.Fields(line[softPos:])
+		if len(values) < 2 {
+			return nil, fmt.Errorf("invalid limits line: %s", line)
+		}
+
+		var err error
+		if l.Soft, err = parseLimitValue(values[0]); err != nil {
+			return nil, fmt.Errorf("invalid soft limit for %s: %w", l.Name, err)
+		}
+		if l.Hard, err = parseLimitValue(values[1]); err != nil {
+			return nil, fmt.Errorf("invalid hard limit for %s: %w", l.Name, err)
+		}
+		if len(values) > 2 {
+			l.Unit = values[2]
+		}
+		res = append(res, l)
+	}
+
+	return res, nil
+}
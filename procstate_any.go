@@ -2,7 +2,53 @@ package runutil
 
 import "time"
 
+// ProcStatus is the scheduling state of a process
+type ProcStatus int
+
+const (
+	ProcUnknown     ProcStatus = iota
+	ProcRunning                // running or runnable
+	ProcSleeping               // interruptible sleep
+	ProcDiskWait               // uninterruptible sleep, usually waiting for IO
+	ProcZombie                 // terminated but not yet reaped by its parent
+	ProcStopped                // stopped by a signal
+	ProcTracingStop            // stopped by a debugger
+	ProcDead                   // being destroyed
+	ProcIdle                   // idle kernel thread
+)
+
+func (s ProcStatus) String() string {
+	switch s {
+	case ProcRunning:
+		return "running"
+	case ProcSleeping:
+		return "sleeping"
+	case ProcDiskWait:
+		return "disk wait"
+	case ProcZombie:
+		return "zombie"
+	case ProcStopped:
+		return "stopped"
+	case ProcTracingStop:
+		return "tracing stop"
+	case ProcDead:
+		return "dead"
+	case ProcIdle:
+		return "idle"
+	default:
+		return "unknown"
+	}
+}
+
 type ProcState interface {
+	// IsRunning returns true only if the process is currently running or runnable, see Alive
 	IsRunning() bool
 	Started() (time.Time, error)
+	State() ProcStatus
+	Alive() bool                // true if the process hasn't terminated
+	CPUTime() time.Duration     // user and system time consumed by the process
+	RSSBytes() uint64           // resident set size
+	Parent() int                // pid of the parent process
+	Cmdline() ([]string, error) // arguments of the process, empty for kernel threads and zombies
+	Exe() (string, error)       // path to the executable of the process
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -21,35 +22,125 @@ const (
 )
 
 type LinuxProcState struct {
-	Pid         int
-	Comm        string // "bash", etc
-	State       byte   // 'R', 'S', 'D', 'Z', 'T', 't', 'W', etc
-	PPid        int    // parent pid
-	PGrp        int    // group id
-	Session     int    // session
-	TtyNr       int    // tty number
-	Tpgid       int
-	Flags       uint   // PF_* flags
-	Minflt      uint64 // faults
-	Cminflt     uint64
-	Majflt      uint64
-	Cmajflt     uint64
-	Utime       uint64
-	Stime       uint64
-	Cutime      uint64
-	Cstime      uint64
-	Priority    int64
-	Nice        int64 // range 19 (low priority) to -20 (high priority).
-	NumThreads  int64
-	Itrealvalue int64  // The time in jiffies before the next SIGALRM
-	StartTime   uint64 // The time the process started after system boot in clock ticks (_SYSTEM_CLK_TCK)
-	Vsize       uint64
-	RSS         int64
-	RSSlim      uint64
+	Pid                 int
+	Comm                string // "bash", etc
+	RawState            byte   // 'R', 'S', 'D', 'Z', 'T', 't', 'W', etc. Was named State before State() was added
+	PPid                int    // parent pid
+	PGrp                int    // group id
+	Session             int    // session
+	TtyNr               int    // tty number
+	Tpgid               int
+	Flags               uint   // PF_* flags
+	Minflt              uint64 // faults
+	Cminflt             uint64
+	Majflt              uint64
+	Cmajflt             uint64
+	Utime               uint64
+	Stime               uint64
+	Cutime              uint64
+	Cstime              uint64
+	Priority            int64
+	Nice                int64 // range 19 (low priority) to -20 (high priority).
+	NumThreads          int64
+	Itrealvalue         int64  // The time in jiffies before the next SIGALRM
+	StartTime           uint64 // The time the process started after system boot in clock ticks (_SYSTEM_CLK_TCK)
+	Vsize               uint64
+	RSS                 int64
+	RSSlim              uint64
+	StartCode           uint64 // address above which program text can run
+	EndCode             uint64 // address below which program text can run
+	StartStack          uint64 // address of the start (bottom) of the stack
+	KstkESP             uint64 // current value of ESP (stack pointer)
+	KstkEIP             uint64 // current EIP (instruction pointer)
+	Signal              uint64 // bitmap of pending signals (obsolete, see /proc/<pid>/status)
+	Blocked             uint64 // bitmap of blocked signals
+	SigIgnore           uint64 // bitmap of ignored signals
+	SigCatch            uint64 // bitmap of caught signals
+	WChan               uint64 // channel in which the process is waiting
+	NSwap               uint64 // not maintained
+	CNSwap              uint64 // not maintained
+	ExitSignal          int    // signal to be sent to parent when we die
+	Processor           int    // CPU number last executed on
+	RTPriority          uint   // real-time scheduling priority, 0 for non real-time processes
+	Policy              uint   // scheduling policy (SCHED_*)
+	DelayacctBlkioTicks uint64 // aggregated block I/O delays, in clock ticks
+	GuestTime           uint64 // guest time of the process, in clock ticks
+	CGuestTime          int64  // guest time of the process's children, in clock ticks
+	StartData           uint64 // address above which program data+bss is placed
+	EndData             uint64 // address below which program data+bss is placed
+	StartBrk            uint64 // address above which program heap can be expanded with brk
+	ArgStart            uint64 // address above which program command-line arguments are placed
+	ArgEnd              uint64 // address below program command-line arguments are placed
+	EnvStart            uint64 // address above which program environment is placed
+	EnvEnd              uint64 // address below which program environment is placed
+	ExitCode            int    // the thread's exit status in the form reported by waitpid
 }
 
 func (s *LinuxProcState) IsRunning() bool {
-	return s.State == 'R'
+	return s.RawState == 'R'
+}
+
+func (s *LinuxProcState) State() ProcStatus {
+	switch s.RawState {
+	case 'R':
+		return ProcRunning
+	case 'S':
+		return ProcSleeping
+	case 'D':
+		return ProcDiskWait
+	case 'Z':
+		return ProcZombie
+	case 'T':
+		return ProcStopped
+	case 't':
+		return ProcTracingStop
+	case 'X', 'x':
+		return ProcDead
+	case 'I':
+		return ProcIdle
+	default:
+		return ProcUnknown
+	}
+}
+
+func (s *LinuxProcState) Alive() bool {
+	switch s.State() {
+	case ProcZombie, ProcDead:
+		return false
+	default:
+		return true
+	}
+}
+
+func (s *LinuxProcState) CPUTime() time.Duration {
+	return time.Duration(s.Utime+s.Stime) * time.Second / _SYSTEM_CLK_TCK
+}
+
+func (s *LinuxProcState) RSSBytes() uint64 {
+	if s.RSS < 0 {
+		return 0
+	}
+	return uint64(s.RSS) * uint64(os.Getpagesize())
+}
+
+func (s *LinuxProcState) Parent() int {
+	return s.PPid
+}
+
+func (s *LinuxProcState) Cmdline() ([]string, error) {
+	buf, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(s.Pid), "cmdline"))
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	// each argument is followed by a nil char
+	return strings.Split(strings.TrimSuffix(string(buf), "\x00"), "\x00"), nil
+}
+
+func (s *LinuxProcState) Exe() (string, error) {
+	return os.Readlink(filepath.Join("/proc", strconv.Itoa(s.Pid), "exe"))
 }
 
 func (s *LinuxProcState) Started() (time.Time, error) {
@@ -123,58 +214,80 @@ func (s *LinuxProcState) parse(data string) error {
 	// see: https://man7.org/linux/man-pages/man5/procfs.5.html
 	// 3947 (bash test) S 3799 3947 3799 34828 3964 4194304 547 1212 0 2 0 0 2 0 20 0 1 0 806660689 10452992 1039 18446744073709551615 94202653388800 94202653965661 140728351794288 0 0 0 65536 3686404 1266761467 1 0 0 17 11 0 0 0 0 0 94202654164112 94202654186268 94202661220352 140728351795906 140728351795918 140728351795918 140728351801324 0
 
-	dec := strings.Fields(data)
-
-	err := eatValue(&dec, &s.Pid) // 1
-	if err != nil {
-		return err
+	// comm (2) can contain anything including spaces and parenthesis, so we look for the last ')'
+	start := strings.IndexByte(data, '(')
+	end := strings.LastIndexByte(data, ')')
+	if start == -1 || end < start {
+		return errors.New("invalid proc state format at comm")
 	}
 
-	// read Comm (2)
-	if len(dec) < 1 {
-		return io.ErrUnexpectedEOF
+	dec := strings.Fields(data[:start])
+	if err := eatValue(&dec, &s.Pid); err != nil { // 1
+		return fmt.Errorf("invalid proc state field 1: %w", err)
 	}
-	comm := dec[0]
-	if len(comm) < 1 || comm[0] != '(' {
-		return errors.New("invalid proc state format at comm")
+	s.Comm = data[start+1 : end]
+
+	dec = strings.Fields(data[end+1:])
+	fields := []any{
+		&s.RawState,            // 3
+		&s.PPid,                // 4
+		&s.PGrp,                // 5
+		&s.Session,             // 6
+		&s.TtyNr,               // 7
+		&s.Tpgid,               // 8
+		&s.Flags,               // 9
+		&s.Minflt,              // 10
+		&s.Cminflt,             // 11
+		&s.Majflt,              // 12
+		&s.Cmajflt,             // 13
+		&s.Utime,               // 14
+		&s.Stime,               // 15
+		&s.Cutime,              // 16
+		&s.Cstime,              // 17
+		&s.Priority,            // 18
+		&s.Nice,                // 19
+		&s.NumThreads,          // 20
+		&s.Itrealvalue,         // 21
+		&s.StartTime,           // 22
+		&s.Vsize,               // 23
+		&s.RSS,                 // 24
+		&s.RSSlim,              // 25
+		&s.StartCode,           // 26
+		&s.EndCode,             // 27
+		&s.StartStack,          // 28
+		&s.KstkESP,             // 29
+		&s.KstkEIP,             // 30
+		&s.Signal,              // 31
+		&s.Blocked,             // 32
+		&s.SigIgnore,           // 33
+		&s.SigCatch,            // 34
+		&s.WChan,               // 35
+		&s.NSwap,               // 36
+		&s.CNSwap,              // 37
+		&s.ExitSignal,          // 38
+		&s.Processor,           // 39
+		&s.RTPriority,          // 40
+		&s.Policy,              // 41
+		&s.DelayacctBlkioTicks, // 42
+		&s.GuestTime,           // 43
+		&s.CGuestTime,          // 44
+		&s.StartData,           // 45
+		&s.EndData,             // 46
+		&s.StartBrk,            // 47
+		&s.ArgStart,            // 48
+		&s.ArgEnd,              // 49
+		&s.EnvStart,            // 50
+		&s.EnvEnd,              // 51
+		&s.ExitCode,            // 52
 	}
-	comm = comm[1:] // strip '('
-	dec = dec[1:]
-	for comm[len(comm)-1] != ')' {
-		if len(dec) < 1 {
-			return io.ErrUnexpectedEOF
+
+	for n, f := range fields {
+		if err := eatValue(&dec, f); err != nil {
+			return fmt.Errorf("invalid proc state field %d: %w", n+3, err)
 		}
-		comm += " " + dec[0]
-		dec = dec[1:]
 	}
-	comm = comm[:len(comm)-1] // strip ')'
-	s.Comm = comm
-
-	eatValue(&dec, &s.State)       // 3
-	eatValue(&dec, &s.PPid)        // 4
-	eatValue(&dec, &s.PGrp)        // 5
-	eatValue(&dec, &s.Session)     // 6
-	eatValue(&dec, &s.TtyNr)       // 7
-	eatValue(&dec, &s.Tpgid)       // 8
-	eatValue(&dec, &s.Flags)       // 9
-	eatValue(&dec, &s.Minflt)      // 10
-	eatValue(&dec, &s.Cminflt)     // 11
-	eatValue(&dec, &s.Majflt)      // 12
-	eatValue(&dec, &s.Cmajflt)     // 13
-	eatValue(&dec, &s.Utime)       // 14
-	eatValue(&dec, &s.Stime)       // 15
-	eatValue(&dec, &s.Cutime)      // 16
-	eatValue(&dec, &s.Cstime)      // 17
-	eatValue(&dec, &s.Priority)    // 18
-	eatValue(&dec, &s.Nice)        // 19
-	eatValue(&dec, &s.NumThreads)  // 20
-	eatValue(&dec, &s.Itrealvalue) // 21
-	eatValue(&dec, &s.StartTime)   // 22
-	eatValue(&dec, &s.Vsize)       // 23
-	eatValue(&dec, &s.RSS)         // 24
-	eatValue(&dec, &s.RSSlim)      // 25
-
-	return err
+
+	return nil
 }
 
 func PidState(pid uint64) (ProcState, error) {
@@ -5,6 +5,8 @@ package runutil
 import (
 	"log"
 	"os"
+	"os/exec"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -21,7 +23,7 @@ func TestLinuxParse(t *testing.T) {
 	}
 
 	log.Printf("test = %+v", s)
-	// {Pid:3947 Comm:bash test State:'S' PPid:3799 PGrp:3947 Session:3799 TtyNr:34828 Tpgid:3964 Flags:4194304 Minflt:547 Cminflt:1212 Majflt:0 Cmajflt:2 Utime:0 Stime:0 Cutime:2 Cstime:0 Priority:20 Nice:0 NumThreads:1 Itrealvalue:0 StartTime:806660689 Vsize:10452992 RSS:1039 RSSlim:18446744073709551615}
+	// {Pid:3947 Comm:bash test RawState:'S' PPid:3799 PGrp:3947 Session:3799 TtyNr:34828 Tpgid:3964 Flags:4194304 Minflt:547 Cminflt:1212 Majflt:0 Cmajflt:2 Utime:0 Stime:0 Cutime:2 Cstime:0 Priority:20 Nice:0 NumThreads:1 Itrealvalue:0 StartTime:806660689 Vsize:10452992 RSS:1039 RSSlim:18446744073709551615}
 
 	if s.Pid != 3947 {
 		t.Fatal("invalid pid in decoded state")
@@ -29,7 +31,7 @@ func TestLinuxParse(t *testing.T) {
 	if s.Comm != "bash test" {
 		t.Fatal("invalid comm in decoded state")
 	}
-	if s.State != 'S' {
+	if s.RawState != 'S' || s.State() != ProcSleeping {
 		t.Fatal("invalid state in decoded state")
 	}
 	if s.RSS != 1039 {
@@ -38,10 +40,17 @@ func TestLinuxParse(t *testing.T) {
 
 	testVal := 30 * time.Millisecond
 
+	// start a new process, as this one may have been running other tests for a while
+	cmd := exec.Command("sleep", "10")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("test failed: %s", err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
 	time.Sleep(testVal)
 
-	// read own value
-	s2, err := PidState(uint64(os.Getpid()))
+	s2, err := PidState(uint64(cmd.Process.Pid))
 	if err != nil {
 		t.Fatalf("test failed: %s", err)
 	}
@@ -51,9 +60,101 @@ func TestLinuxParse(t *testing.T) {
 		t.Fatalf("test failed: %s", err)
 	}
 
+	// the start time has a resolution of 10ms, and the test may be slowed down
 	ago := time.Since(tv).Truncate(10 * time.Millisecond)
 
-	if ago != testVal {
+	if ago < testVal-10*time.Millisecond || ago > testVal+100*time.Millisecond {
 		t.Fatalf("expected %s uptime of process, but got %s", testVal, ago)
 	}
 }
+
+func TestLinuxPidInfo(t *testing.T) {
+	info, err := LinuxPidInfo(uint64(os.Getpid()))
+	if err != nil {
+		t.Fatalf("test failed: %s", err)
+	}
+
+	if info.Stat.Pid != os.Getpid() || info.Status.Pid != os.Getpid() {
+		t.Errorf("invalid pid in process info")
+	}
+	if info.Stat.PPid != os.Getppid() || info.Status.PPid != os.Getppid() {
+		t.Errorf("invalid parent pid in process info")
+	}
+	if info.Status.Uid[0] != os.Getuid() || info.Status.Gid[0] != os.Getgid() {
+		t.Errorf("invalid uid/gid in process info: %v %v", info.Status.Uid, info.Status.Gid)
+	}
+	if info.Status.VmHWM == 0 || info.Statm.Resident == 0 {
+		t.Errorf("invalid memory values in process info")
+	}
+	if info.Stat.Processor < 0 || info.Stat.ExitSignal != int(syscall.SIGCHLD) {
+		t.Errorf("invalid stat values in process info: %+v", info.Stat)
+	}
+	if info.IO == nil || info.IO.RChar == 0 {
+		t.Errorf("invalid io values in process info")
+	}
+
+	nofile, ok := info.Limits.Get("Max open files")
+	if !ok {
+		t.Fatalf("missing open files limit")
+	}
+	var rlim syscall.Rlimit
+	syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlim)
+	if nofile.Soft != rlim.Cur || nofile.Hard != rlim.Max || nofile.Unit != "files" {
+		t.Errorf("invalid open files limit %+v, expected %+v", nofile, rlim)
+	}
+	if cpu, ok := info.Limits.Get("Max cpu time"); !ok || cpu.Unit != "seconds" {
+		t.Errorf("invalid cpu time limit %+v", cpu)
+	}
+}
+
+func TestLinuxParseErrors(t *testing.T) {
+	s := &LinuxProcState{}
+	if err := s.parse("3947 (bash) S 3799 3947 abc"); err == nil {
+		t.Errorf("invalid stat should have failed to parse")
+	}
+	if err := s.parse("3947 (bash) S 3799"); err == nil {
+		t.Errorf("truncated stat should have failed to parse")
+	}
+	if err := s.parse("3947 (a) b) S 3799 3947 3799 34828 3964 4194304 547 1212 0 2 0 0 2 0 20 0 1 0 806660689 10452992 1039 18446744073709551615 94202653388800 94202653965661 140728351794288 0 0 0 65536 3686404 1266761467 1 0 0 17 11 0 0 0 0 0 94202654164112 94202654186268 94202661220352 140728351795906 140728351795918 140728351795918 140728351801324 0"); err != nil {
+		t.Errorf("stat with parenthesis in comm failed to parse: %s", err)
+	} else if s.Comm != "a) b" || s.ExitCode != 0 || s.EnvEnd != 140728351801324 {
+		t.Errorf("invalid values decoded: %+v", s)
+	}
+}
+
+func TestProcState(t *testing.T) {
+	s, err := PidState(uint64(os.Getpid()))
+	if err != nil {
+		t.Fatalf("test failed: %s", err)
+	}
+
+	// stat reports the state of the main thread, which may not be the one running this test
+	if (s.State() != ProcRunning && s.State() != ProcSleeping) || !s.Alive() {
+		t.Errorf("invalid state %s for own process", s.State())
+	}
+	if s.Parent() != os.Getppid() {
+		t.Errorf("invalid parent %d, expected %d", s.Parent(), os.Getppid())
+	}
+	if s.RSSBytes() < uint64(os.Getpagesize()) {
+		t.Errorf("invalid rss %d", s.RSSBytes())
+	}
+	if s.CPUTime() < 0 {
+		t.Errorf("invalid cpu time %s", s.CPUTime())
+	}
+
+	args, err := s.Cmdline()
+	if err != nil {
+		t.Fatalf("test failed: %s", err)
+	}
+	if len(args) != len(os.Args) || args[0] != os.Args[0] {
+		t.Errorf("invalid cmdline %q, expected %q", args, os.Args)
+	}
+
+	exe, err := s.Exe()
+	if err != nil {
+		t.Fatalf("test failed: %s", err)
+	}
+	if self, _ := os.Executable(); exe != self {
+		t.Errorf("invalid exe %s, expected %s", exe, self)
+	}
+}
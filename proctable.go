@@ -0,0 +1,8 @@
+//go:build !linux
+
+package runutil
+
+// Processes returns a snapshot of all the processes running on the system
+func Processes() (ProcTable, error) {
+	return nil, ErrNotSupported
+}
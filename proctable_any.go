@@ -0,0 +1,148 @@
+package runutil
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Proc is a process as found in a ProcTable
+type Proc struct {
+	Pid     int
+	PPid    int
+	Name    string    // name of the process, "bash", etc
+	State   ProcState // *LinuxProcState on linux
+	Cmdline []string  // empty for kernel threads and zombies
+	Exe     string    // empty if it couldn't be read
+}
+
+// ProcTable is a snapshot of the processes running on the system, indexed by pid
+type ProcTable map[int]*Proc
+
+// ProcNode is a process in the tree returned by ProcTable.Tree
+type ProcNode struct {
+	*Proc
+	Children []*ProcNode
+}
+
+// children returns the list of pids of the children of each process, sorted
+func (t ProcTable) children() map[int][]int {
+	res := make(map[int][]int)
+	for pid, p := range t {
+		res[p.PPid] = append(res[p.PPid], pid)
+	}
+	for _, l := range res {
+		sort.Ints(l)
+	}
+	return res
+}
+
+// Children returns the direct children of the given pid
+func (t ProcTable) Children(pid int) []*Proc {
+	var res []*Proc
+	for _, c := range t.children()[pid] {
+		res = append(res, t[c])
+	}
+	return res
+}
+
+// Descendants returns all the processes spawned under the given pid, parents first
+func (t ProcTable) Descendants(pid int) []*Proc {
+	children := t.children()
+
+	// pids can be reused while the snapshot is taken, so watch for loops
+	var res []*Proc
+	seen := map[int]bool{pid: true}
+	queue := children[pid]
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		res = append(res, t[c])
+		queue = append(queue, children[c]...)
+	}
+	return res
+}
+
+// Ancestors returns the parent of the given pid, then its parent, etc, up to the
+// first process not found in the table.
+func (t ProcTable) Ancestors(pid int) []*Proc {
+	var res []*Proc
+	seen := map[int]bool{pid: true}
+
+	p, ok := t[pid]
+	for ok {
+		if seen[p.PPid] {
+			break
+		}
+		seen[p.PPid] = true
+		if p, ok = t[p.PPid]; ok {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// Tree returns the processes of the table as trees, with roots being processes
+// whose parent isn't in the table (typically pid 1 and kernel threads' parent 2).
+func (t ProcTable) Tree() []*ProcNode {
+	children := t.children()
+
+	seen := make(map[int]bool)
+
+	var build func(pid int) *ProcNode
+	build = func(pid int) *ProcNode {
+		seen[pid] = true
+		n := &ProcNode{Proc: t[pid]}
+		for _, c := range children[pid] {
+			if !seen[c] {
+				n.Children = append(n.Children, build(c))
+			}
+		}
+		return n
+	}
+
+	var roots []int
+	for pid, p := range t {
+		if _, found := t[p.PPid]; !found || p.PPid == pid {
+			roots = append(roots, pid)
+		}
+	}
+	sort.Ints(roots)
+
+	res := make([]*ProcNode, 0, len(roots))
+	for _, pid := range roots {
+		res = append(res, build(pid))
+	}
+	return res
+}
+
+// String renders the node and its children similar to pstree
+func (n *ProcNode) String() string {
+	b := &strings.Builder{}
+	n.render(b, "", "")
+	return b.String()
+}
+
+func (n *ProcNode) render(b *strings.Builder, prefix, childPrefix string) {
+	b.WriteString(prefix)
+	b.WriteString(strconv.Itoa(n.Pid))
+	b.WriteByte(' ')
+	if len(n.Cmdline) > 0 {
+		b.WriteString(strings.Join(n.Cmdline, " "))
+	} else {
+		b.WriteString("[" + n.Name + "]")
+	}
+	b.WriteByte('\n')
+
+	for i, c := range n.Children {
+		if i == len(n.Children)-1 {
+			c.render(b, childPrefix+"└─ ", childPrefix+"   ")
+		} else {
+			c.render(b, childPrefix+"├─ ", childPrefix+"│  ")
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package runutil
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Processes returns a snapshot of all the processes running on the system. Processes
+// exiting while the snapshot is taken may or may not be part of it.
+func Processes() (ProcTable, error) {
+	l, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(ProcTable)
+	for _, proc := range l {
+		pid, err := strconv.ParseUint(proc.Name(), 10, 64)
+		if err != nil {
+			// not numeric. Don't care.
+			continue
+		}
+
+		st, err := LinuxPidState(pid)
+		if err != nil {
+			// process is gone
+			continue
+		}
+
+		p := &Proc{
+			Pid:   st.Pid,
+			PPid:  st.PPid,
+			Name:  st.Comm,
+			State: st,
+		}
+		// these may fail depending on permissions
+		p.Cmdline, _ = st.Cmdline()
+		p.Exe, _ = os.Readlink(filepath.Join("/proc", proc.Name(), "exe"))
+
+		res[p.Pid] = p
+	}
+	return res, nil
+}
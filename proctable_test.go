@@ -0,0 +1,120 @@
+//go:build linux
+
+package runutil
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestProcesses(t *testing.T) {
+	p, err := Start("/bin/sh", "-c", "sleep 10 & sleep 10 & wait")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	defer p.Wait()
+	defer syscall.Kill(-p.Pid(), syscall.SIGKILL)
+
+	// give some time to sh to start both sleep
+	var tbl ProcTable
+	for i := 0; i < 50; i++ {
+		time.Sleep(10 * time.Millisecond)
+		tbl, err = Processes()
+		if err != nil {
+			t.Fatalf("failed to run test: %s", err)
+		}
+		if len(tbl.Children(p.Pid())) == 2 {
+			break
+		}
+	}
+
+	children := tbl.Children(p.Pid())
+	if len(children) != 2 {
+		t.Fatalf("expected 2 children for sh, got %d", len(children))
+	}
+	for _, c := range children {
+		if c.Name != "sleep" || len(c.Cmdline) != 2 || c.Cmdline[1] != "10" || !strings.HasSuffix(c.Exe, "/sleep") {
+			t.Errorf("unexpected child %+v", c)
+		}
+	}
+
+	// the test process may have adopted orphans of other tests, only check sh
+	desc := tbl.Descendants(p.Pid())
+	if len(desc) != 2 || desc[0].Name != "sleep" || desc[1].Name != "sleep" {
+		t.Errorf("expected 2 sleep as descendants of sh, got %d processes", len(desc))
+	}
+
+	anc := tbl.Ancestors(children[0].Pid)
+	if len(anc) < 2 || anc[0].Pid != p.Pid() || anc[1].Pid != os.Getpid() {
+		t.Errorf("invalid ancestors for sleep")
+	}
+
+	var found bool
+	for _, n := range tbl.Tree() {
+		if strings.Contains(n.String(), "─ "+strconv.Itoa(children[0].Pid)+" sleep 10\n") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("sleep not found in process tree")
+	}
+}
+
+func TestFindProcesses(t *testing.T) {
+	p, err := Start("sleep", "31337")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	defer p.Wait()
+	defer p.Signal(os.Kill)
+
+	self, err := LinuxPidState(uint64(os.Getpid()))
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	exe, err := filepath.EvalSymlinks(p.x.cmd.Path)
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	cg, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	cgPath := strings.SplitN(strings.SplitN(string(cg), "\n", 2)[0], ":", 3)[2]
+
+	m := And(
+		MatchComm("sleep"),
+		MatchCommRegexp(regexp.MustCompile("^sl")),
+		MatchArgs("sleep 31337"),
+		MatchArgsRegexp(regexp.MustCompile(`^sleep 3133[0-9]$`)),
+		MatchExe(exe),
+		MatchUID(os.Geteuid()),
+		MatchParent(os.Getpid()),
+		MatchSession(self.Session),
+		MatchCgroup(cgPath),
+		Not(MatchComm("bash")),
+		Or(MatchUID(-1), MatchComm("sleep")),
+	)
+
+	res, err := FindProcesses(m)
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	if len(res) != 1 || res[0].Pid != p.Pid() {
+		t.Errorf("expected to find sleep process %d, got %d processes", p.Pid(), len(res))
+	}
+
+	// we should never find ourselves
+	res, _ = FindProcesses(MatchParent(os.Getppid()))
+	for _, r := range res {
+		if r.Pid == os.Getpid() {
+			t.Errorf("FindProcesses returned the current process")
+		}
+	}
+}
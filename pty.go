@@ -0,0 +1,13 @@
+//go:build !linux
+
+package runutil
+
+// Pty starts the command with a pseudo-terminal. Only supported on Linux.
+func (c *Command) Pty() (*Pty, error) {
+	return nil, ErrNotSupported
+}
+
+// Resize changes the size of the terminal
+func (p *Pty) Resize(rows, cols uint16) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,30 @@
+package runutil
+
+import "os"
+
+// Pty is a command running with a pseudo-terminal as its controlling terminal. It is
+// a Pipe returning what the command writes to the terminal, and data written to it
+// is received by the command as if typed on the terminal.
+type Pty struct {
+	*processPipe
+	f *os.File // master side of the terminal
+}
+
+// RunPty starts the command in background with a new pseudo-terminal as its stdin,
+// stdout and stderr. Once EOF is reached, the final Read returns the command's
+// error, if any. Close it to hang up the terminal and stop the command.
+func RunPty(arg ...string) (*Pty, error) {
+	return Cmd(arg...).Pty()
+}
+
+// RunPty starts the command in background with a new pseudo-terminal as its stdin,
+// stdout and stderr. Once EOF is reached, the final Read returns the command's
+// error, if any. Close it to hang up the terminal and stop the command.
+func (e Env) RunPty(arg ...string) (*Pty, error) {
+	return Cmd(arg...).Env(e).Pty()
+}
+
+// Write sends p to the terminal, as input for the command
+func (p *Pty) Write(b []byte) (int, error) {
+	return p.f.Write(b)
+}
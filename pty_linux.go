@@ -0,0 +1,128 @@
+package runutil
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// Pty starts the command in background as a session leader, with a new
+// pseudo-terminal as its controlling terminal. The terminal is used as its stdin
+// and stderr unless they were set on the Command, and always as its stdout.
+func (c *Command) Pty() (*Pty, error) {
+	x, err := c.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	m, s, err := openPty()
+	if err != nil {
+		return nil, x.finish(err)
+	}
+	// set before starting, so the command never sees an empty size
+	if err := setWinsize(m, 24, 80); err != nil {
+		m.Close()
+		s.Close()
+		return nil, x.finish(err)
+	}
+
+	x.cmd.Stdout = s
+	if x.cmd.Stdin == nil {
+		x.cmd.Stdin = s
+	}
+	if x.cmd.Stderr == nil {
+		x.cmd.Stderr = s
+	}
+	// a session leader is also the leader of its process group
+	attr := x.cmd.SysProcAttr
+	attr.Setpgid = false
+	attr.Setsid = true
+	x.group = true
+	attr.Setctty = true
+	attr.Ctty = 1 // stdout in the child
+
+	err = x.startCmd()
+	s.Close()
+	if err != nil {
+		m.Close()
+		return nil, x.finish(err)
+	}
+
+	return &Pty{processPipe: newProcessPipe(ptyReader{m}, x), f: m}, nil
+}
+
+// Resize changes the size of the terminal, which sends SIGWINCH to the command
+func (p *Pty) Resize(rows, cols uint16) error {
+	return setWinsize(p.f, rows, cols)
+}
+
+func setWinsize(f *os.File, rows, cols uint16) error {
+	ws := struct{ row, col, xpixel, ypixel uint16 }{row: rows, col: cols}
+	return ioctlFile(f, syscall.TIOCSWINSZ, unsafe.Pointer(&ws))
+}
+
+// openPty opens a new pseudo-terminal pair
+func openPty() (master, slave *os.File, err error) {
+	m, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var unlock int32
+	if err = ioctlFile(m, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		m.Close()
+		return nil, nil, err
+	}
+	var n uint32
+	if err = ioctlFile(m, syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		m.Close()
+		return nil, nil, err
+	}
+
+	s, err := os.OpenFile("/dev/pts/"+strconv.FormatUint(uint64(n), 10), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		m.Close()
+		return nil, nil, err
+	}
+	return m, s, nil
+}
+
+// ioctlFile runs an ioctl on f without switching it to blocking mode as Fd would
+func ioctlFile(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	c, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var e syscall.Errno
+	err = c.Control(func(fd uintptr) {
+		_, _, e = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	})
+	if err != nil {
+		return err
+	}
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// ptyReader reads the master side of a terminal, which returns EIO instead of EOF
+// once the other side has been closed by every process
+type ptyReader struct {
+	f *os.File
+}
+
+func (r ptyReader) Read(p []byte) (int, error) {
+	n, err := r.f.Read(p)
+	if errors.Is(err, syscall.EIO) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r ptyReader) Close() error {
+	return r.f.Close()
+}
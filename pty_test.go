@@ -0,0 +1,52 @@
+//go:build linux
+
+package runutil
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestPty(t *testing.T) {
+	p, err := RunPty("/bin/sh", "-c", "test -t 0 && test -t 1 && test -t 2 && echo tty; stty size; exit 3")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	buf, err := io.ReadAll(p)
+	if string(buf) != "tty\r\n24 80\r\n" {
+		t.Errorf("invalid output: %q", buf)
+	}
+	var e *exec.ExitError
+	if !errors.As(err, &e) || e.ExitCode() != 3 {
+		t.Errorf("failed, the command was supposed to return error 3, got %v", err)
+	}
+
+	// interactive use, with the terminal resized
+	p, err = Cmd("/bin/sh", "-c", "stty -echo; echo ready; read x; stty size; echo got $x").Pty()
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	defer p.Close()
+
+	r := bufio.NewReader(p)
+	if l, err := r.ReadString('\n'); l != "ready\r\n" {
+		t.Fatalf("invalid output: %q %v", l, err)
+	}
+	if err := p.Resize(40, 100); err != nil {
+		t.Errorf("failed to resize: %s", err)
+	}
+	if _, err := io.WriteString(p, "hello\n"); err != nil {
+		t.Errorf("failed to write: %s", err)
+	}
+	buf, err = io.ReadAll(r)
+	if err != nil {
+		t.Errorf("failed to run test: %s", err)
+	}
+	if s := strings.ReplaceAll(string(buf), "\r", ""); s != "40 100\ngot hello\n" {
+		t.Errorf("invalid output: %q", buf)
+	}
+}
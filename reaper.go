@@ -2,6 +2,13 @@
 
 package runutil
 
+import "context"
+
 func Reap() error {
 	return nil
 }
+
+// StartReaper is only supported on linux
+func StartReaper(ctx context.Context) (<-chan ReapedProcess, error) {
+	return nil, ErrNotSupported
+}
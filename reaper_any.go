@@ -0,0 +1,11 @@
+package runutil
+
+import "syscall"
+
+// ReapedProcess is sent by the reaper started with StartReaper for each orphan
+// process it collected
+type ReapedProcess struct {
+	Pid    int
+	Status syscall.WaitStatus
+	Rusage syscall.Rusage
+}
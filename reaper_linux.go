@@ -0,0 +1,140 @@
+package runutil
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	_PR_SET_CHILD_SUBREAPER = 36
+	_PR_GET_CHILD_SUBREAPER = 37
+)
+
+// Reap collects the exit status of any zombie child process, except the ones started
+// by this package which are waited separately. Children started with os/exec by other
+// packages can still have their status stolen.
+func Reap() error {
+	reapLk.Lock()
+	defer reapLk.Unlock()
+
+	return reapOrphans(nil)
+}
+
+// reapOrphans waits for all the zombie children of the current process not started
+// by this package. reapLk must be held.
+func reapOrphans(report func(ReapedProcess)) error {
+	l, err := os.ReadDir("/proc")
+	if err != nil {
+		return err
+	}
+
+	self := os.Getpid()
+	for _, proc := range l {
+		pid, err := strconv.ParseUint(proc.Name(), 10, 64)
+		if err != nil {
+			// not numeric. Don't care.
+			continue
+		}
+
+		st, err := LinuxPidState(pid)
+		if err != nil || st.PPid != self || st.State() != ProcZombie || isOwned(st.Pid) {
+			continue
+		}
+
+		r := ReapedProcess{Pid: st.Pid}
+		wpid, err := syscall.Wait4(r.Pid, &r.Status, syscall.WNOHANG, &r.Rusage)
+		if err != nil {
+			if err == syscall.ECHILD {
+				// someone else waited it already
+				continue
+			}
+			return err
+		}
+		if wpid == r.Pid && report != nil {
+			report(r)
+		}
+	}
+	return nil
+}
+
+// forwardSignal sends sig to all the children of the current process. Children that
+// lead their own process group receive it for the whole group.
+func forwardSignal(sig syscall.Signal) {
+	tbl, err := Processes()
+	if err != nil {
+		return
+	}
+	for _, p := range tbl.Children(os.Getpid()) {
+		if st, ok := p.State.(*LinuxProcState); ok && st.PGrp == p.Pid {
+			syscall.Kill(-p.Pid, sig)
+		} else {
+			syscall.Kill(p.Pid, sig)
+		}
+	}
+}
+
+// StartReaper makes the current process a child subreaper (or uses the fact it is
+// pid 1) so that orphaned descendants are re-parented to it, and reaps them as they
+// exit until ctx is done. The status of each reaped process is sent on the returned
+// channel, dropping reports if the channel is full. Commands started by this package
+// are not affected. Once ctx is done, the subreaper setting is restored to what it
+// was before, and the returned channel is closed.
+//
+// SIGTERM and SIGINT received by the process are forwarded to its children. As with
+// signal.Notify, this means these signals will not terminate the process anymore
+// unless handled by the caller.
+func StartReaper(ctx context.Context) (<-chan ReapedProcess, error) {
+	var prev int32
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, _PR_GET_CHILD_SUBREAPER, uintptr(unsafe.Pointer(&prev)), 0); errno != 0 {
+		return nil, errno
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, _PR_SET_CHILD_SUBREAPER, 1, 0); errno != 0 {
+		return nil, errno
+	}
+
+	sigs := make(chan os.Signal, 16)
+	signal.Notify(sigs, syscall.SIGCHLD, syscall.SIGTERM, syscall.SIGINT)
+
+	res := make(chan ReapedProcess, 64)
+	report := func(r ReapedProcess) {
+		select {
+		case res <- r:
+		default:
+		}
+	}
+	reap := func() {
+		reapLk.Lock()
+		defer reapLk.Unlock()
+		reapOrphans(report)
+	}
+
+	go func() {
+		defer close(res)
+		defer signal.Stop(sigs)
+
+		// there may be zombies already
+		reap()
+
+		for {
+			select {
+			case <-ctx.Done():
+				syscall.RawSyscall(syscall.SYS_PRCTL, _PR_SET_CHILD_SUBREAPER, uintptr(prev), 0)
+				// orphans that exited in the meantime
+				reap()
+				return
+			case sig := <-sigs:
+				if sig == syscall.SIGCHLD {
+					reap()
+				} else {
+					forwardSignal(sig.(syscall.Signal))
+				}
+			}
+		}
+	}()
+
+	return res, nil
+}
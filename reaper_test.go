@@ -0,0 +1,74 @@
+//go:build linux
+
+package runutil
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestStartReaper(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reaped, err := StartReaper(ctx)
+	if err != nil {
+		t.Skipf("subreaper not available: %s", err)
+	}
+
+	// run commands in parallel, none of them should fail because of the reaper
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := RunGet("/bin/sh", "-c", "exit 0"); err != nil {
+				t.Errorf("command failed: %s", err)
+			}
+		}()
+	}
+
+	// sleep is orphaned when sh exits and should be re-parented to us
+	out, err := RunGet("/bin/sh", "-c", "sleep 0.1 >/dev/null & echo $!")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+
+	wg.Wait()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case r := <-reaped:
+			if r.Pid != pid {
+				continue
+			}
+			if !r.Status.Exited() || r.Status.ExitStatus() != 0 {
+				t.Errorf("unexpected status for orphan: %v", r.Status)
+			}
+
+			// the process is not a subreaper anymore once ctx is done
+			cancel()
+			for range reaped {
+			}
+			var v int32
+			syscall.RawSyscall(syscall.SYS_PRCTL, _PR_GET_CHILD_SUBREAPER, uintptr(unsafe.Pointer(&v)), 0)
+			if v != 0 {
+				t.Errorf("failed, the process is still a subreaper")
+			}
+			return
+		case <-timeout:
+			t.Fatalf("orphan %d was not reaped", pid)
+		}
+	}
+}
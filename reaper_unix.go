@@ -1,8 +1,9 @@
-//go:build !windows
+//go:build !windows && !linux
 
 package runutil
 
 import (
+	"context"
 	"errors"
 	"syscall"
 )
@@ -24,3 +25,8 @@ func Reap() error {
 		//log.Printf("main: clearing zombie process with pid %d", wpid)
 	}
 }
+
+// StartReaper is only supported on linux
+func StartReaper(ctx context.Context) (<-chan ReapedProcess, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,77 @@
+package runutil
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// Result describes how a command ran, once it completed
+type Result struct {
+	Path       string         // resolved path of the command
+	ExitCode   int            // exit code, -1 if the command was killed by a signal
+	Signal     syscall.Signal // signal that killed the command, if any
+	CoreDump   bool           // true if the command dumped core
+	UserTime   time.Duration
+	SystemTime time.Duration
+	MaxRSS     int64 // maximum resident set size in bytes, 0 if not available
+	Start      time.Time
+	End        time.Time
+	State      *os.ProcessState
+}
+
+// Duration returns the wall-clock time the command ran for
+func (r *Result) Duration() time.Duration {
+	return r.End.Sub(r.Start)
+}
+
+// result returns the Result of the execution, which must have completed
+func (x *execution) result() *Result {
+	res := &Result{Path: x.path, ExitCode: -1, Start: x.startAt, End: x.endAt}
+
+	st := x.cmd.ProcessState
+	if st == nil {
+		// never started
+		return res
+	}
+	res.State = st
+	res.ExitCode = st.ExitCode()
+	res.UserTime = st.UserTime()
+	res.SystemTime = st.SystemTime()
+	res.MaxRSS = maxRSS(st)
+	if ws, ok := st.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		res.Signal = ws.Signal()
+		res.CoreDump = ws.CoreDump()
+	}
+	return res
+}
+
+// exitCode returns the exit code of the command, or -1 if it didn't complete yet
+func (x *execution) exitCode() int {
+	select {
+	case <-x.done:
+		return x.cmd.ProcessState.ExitCode()
+	default:
+		return -1
+	}
+}
+
+// RunResult is the same as Run, and also returns the Result of the command
+func RunResult(arg ...string) (*Result, error) {
+	return Cmd(arg...).RunResult()
+}
+
+// RunGetResult is the same as RunGet, and also returns the Result of the command
+func RunGetResult(arg ...string) ([]byte, *Result, error) {
+	return Cmd(arg...).GetResult()
+}
+
+// RunResult is the same as Run, and also returns the Result of the command
+func (e Env) RunResult(arg ...string) (*Result, error) {
+	return Cmd(arg...).Env(e).RunResult()
+}
+
+// RunGetResult is the same as RunGet, and also returns the Result of the command
+func (e Env) RunGetResult(arg ...string) ([]byte, *Result, error) {
+	return Cmd(arg...).Env(e).GetResult()
+}
@@ -0,0 +1,65 @@
+package runutil
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestResult(t *testing.T) {
+	res, err := RunResult("/bin/sh", "-c", "exit 3")
+	if err == nil || res == nil {
+		t.Fatalf("failed, expected an error and a result, got %v %v", res, err)
+	}
+	if res.ExitCode != 3 || res.Signal != 0 || !strings.HasSuffix(res.Path, "/sh") {
+		t.Errorf("invalid result: %+v", res)
+	}
+	if res.Start.IsZero() || res.End.Before(res.Start) {
+		t.Errorf("invalid times in result: %+v", res)
+	}
+
+	out, res, err := RunGetResult("/bin/sh", "-c", "head -c 8M /dev/zero | tr '\\0' x >/dev/null; echo ok")
+	if err != nil || string(out) != "ok\n" {
+		t.Fatalf("failed to run test: %q %v", out, err)
+	}
+	if res.ExitCode != 0 || res.MaxRSS <= 0 || res.Duration() <= 0 {
+		t.Errorf("invalid result: %+v", res)
+	}
+
+	// killed by a signal
+	res, _ = Cmd("sleep", "10").Timeout(50 * time.Millisecond).RunResult()
+	if res.ExitCode != -1 || res.Signal != syscall.SIGKILL {
+		t.Errorf("invalid result for killed command: %+v", res)
+	}
+}
+
+func TestPipeResult(t *testing.T) {
+	r, err := RunRead("/bin/sh", "-c", "echo $$; exit 4")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	if r.ExitCode() != -1 {
+		t.Errorf("failed, the command is not supposed to have completed yet")
+	}
+	buf, _ := io.ReadAll(r)
+	if pid, _ := strconv.Atoi(strings.TrimSpace(string(buf))); pid != r.Pid() {
+		t.Errorf("invalid pid %d, expected %s", r.Pid(), buf)
+	}
+	res, err := r.Wait()
+	if err == nil || res.ExitCode != 4 || r.ExitCode() != 4 {
+		t.Errorf("invalid result: %+v %v", res, err)
+	}
+
+	p, err := NewPipeline(CmdStage("echo", "hello"), CmdStage("cat")).Start(nil)
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	io.ReadAll(p)
+	res, err = p.Wait()
+	if err != nil || res == nil || !strings.HasSuffix(res.Path, "/cat") || p.ExitCode() != 0 {
+		t.Errorf("invalid result: %+v %v", res, err)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package runutil
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+func maxRSS(st *os.ProcessState) int64 {
+	ru, ok := st.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		// already in bytes
+		return int64(ru.Maxrss)
+	}
+	return int64(ru.Maxrss) * 1024
+}
@@ -0,0 +1,7 @@
+package runutil
+
+import "os"
+
+func maxRSS(st *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package runutil
+
+func (x *execution) setupRLimits(l []RLimit) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,28 @@
+package runutil
+
+// LimitUnlimited is the value used in RLimit and LinuxProcLimit for limits set to "unlimited"
+const LimitUnlimited = ^uint64(0)
+
+// RLimit is a resource limit applied to a command with RLimits, as with setrlimit(2)
+type RLimit struct {
+	Resource int    // one of syscall.RLIMIT_*
+	Soft     uint64 // LimitUnlimited if unlimited
+	Hard     uint64 // LimitUnlimited if unlimited
+}
+
+// RLimitError is returned when a command was killed for exceeding the soft limit
+// of one of its RLimits. errors.As can still be used to reach the underlying
+// *exec.ExitError.
+type RLimitError struct {
+	Resource int    // syscall.RLIMIT_CPU or syscall.RLIMIT_FSIZE
+	Name     string // "RLIMIT_CPU" or "RLIMIT_FSIZE"
+	Err      error
+}
+
+func (e *RLimitError) Error() string {
+	return e.Err.Error() + " (" + e.Name + " exceeded)"
+}
+
+func (e *RLimitError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,45 @@
+package runutil
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+// setupRLimits has the limits applied by the helper, right before it runs the command
+func (x *execution) setupRLimits(l []RLimit) error {
+	x.rlimits = l
+	x.useHelper().RLimits = l
+	x.exit = append(x.exit, x.rlimitError)
+	return nil
+}
+
+// rlimitError wraps err in a *RLimitError if the command was killed by the signal
+// sent when exceeding one of its limits
+func (x *execution) rlimitError(err error) error {
+	var e *exec.ExitError
+	if !errors.As(err, &e) {
+		return err
+	}
+	ws, ok := e.Sys().(syscall.WaitStatus)
+	if !ok || !ws.Signaled() {
+		return err
+	}
+
+	var res int
+	var name string
+	switch ws.Signal() {
+	case syscall.SIGXCPU:
+		res, name = syscall.RLIMIT_CPU, "RLIMIT_CPU"
+	case syscall.SIGXFSZ:
+		res, name = syscall.RLIMIT_FSIZE, "RLIMIT_FSIZE"
+	default:
+		return err
+	}
+	for _, l := range x.rlimits {
+		if l.Resource == res {
+			return &RLimitError{Resource: res, Name: name, Err: err}
+		}
+	}
+	return err
+}
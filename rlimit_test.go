@@ -0,0 +1,62 @@
+//go:build linux
+
+package runutil
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestRLimits(t *testing.T) {
+	res, err := Cmd("/bin/sh", "-c", "ulimit -n; ulimit -c").RLimits(
+		RLimit{Resource: syscall.RLIMIT_NOFILE, Soft: 42, Hard: 42},
+		RLimit{Resource: syscall.RLIMIT_CORE, Soft: 0, Hard: 0},
+	).Get()
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	if string(res) != "42\n0\n" {
+		t.Errorf("invalid limits applied: %q", res)
+	}
+
+	// the command is not traced, and can be combined with a sandbox
+	res, err = Cmd("/bin/sh", "-c", "grep TracerPid /proc/self/status; ulimit -n").
+		RLimits(RLimit{Resource: syscall.RLIMIT_NOFILE, Soft: 42, Hard: 42}).
+		Get()
+	if err != nil || string(res) != "TracerPid:\t0\n42\n" {
+		t.Errorf("invalid output: %q %v", res, err)
+	}
+	if err := Cmd("true").Sandbox(&Sandbox{}).Run(); err == nil {
+		res, err = Cmd("/bin/sh", "-c", "ulimit -n").RLimits(RLimit{Resource: syscall.RLIMIT_NOFILE, Soft: 42, Hard: 42}).Sandbox(&Sandbox{}).Get()
+		if err != nil || string(res) != "42\n" {
+			t.Errorf("invalid limits applied in sandbox: %q %v", res, err)
+		}
+	}
+
+	// writing past the file size limit kills the command with SIGXFSZ
+	err = Cmd("/bin/sh", "-c", "exec head -c 8192 /dev/zero > "+t.TempDir()+"/out").
+		RLimits(RLimit{Resource: syscall.RLIMIT_FSIZE, Soft: 4096, Hard: 4096}).
+		Run()
+	var e *RLimitError
+	if !errors.As(err, &e) {
+		t.Fatalf("failed, the command was supposed to return an error of type RLimitError, got %T (%v)", err, err)
+	}
+	if e.Resource != syscall.RLIMIT_FSIZE || !strings.Contains(err.Error(), "RLIMIT_FSIZE") {
+		t.Errorf("invalid limit reported: %s", err)
+	}
+	var ee *exec.ExitError
+	if !errors.As(err, &ee) {
+		t.Errorf("failed, the command was supposed to return an error of type exec.ExitError, got %T", err)
+	}
+
+	// CPU time, the soft limit sends SIGXCPU
+	err = Cmd("/bin/sh", "-c", "while :; do :; done").
+		RLimits(RLimit{Resource: syscall.RLIMIT_CPU, Soft: 1, Hard: 10}).
+		Run()
+	if !errors.As(err, &e) || e.Resource != syscall.RLIMIT_CPU {
+		t.Errorf("failed, the command was supposed to exceed RLIMIT_CPU, got %v", err)
+	}
+}
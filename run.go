@@ -1,149 +1,67 @@
 package runutil
 
 import (
-	"encoding/json"
+	"context"
 	"io"
-	"os"
-	"os/exec"
 )
 
 // Run is a very simple invokation of command run, with output forwarded to stdout. This will wait for the command to complete.
 func Run(arg ...string) error {
-	if len(arg) == 0 {
-		return ErrCommandMissing
-	}
-
-	cmd, err := exec.LookPath(arg[0])
-	if err != nil {
-		return err
-	}
-
-	c := &exec.Cmd{
-		Path:   cmd,
-		Args:   arg,
-		Stdout: os.Stdout,
-		Stderr: os.Stderr,
-	}
+	return Cmd(arg...).Run()
+}
 
-	return c.Run()
+// RunContext is the same as Run, but kills the command if ctx is done before it completes
+func RunContext(ctx context.Context, arg ...string) error {
+	return Cmd(arg...).Context(ctx).Run()
 }
 
 // RunWrite executes the command and passes r as its input, waiting for it to complete.
 func RunWrite(r io.Reader, arg ...string) error {
-	if len(arg) == 0 {
-		return ErrCommandMissing
-	}
-
-	cmd, err := exec.LookPath(arg[0])
-	if err != nil {
-		return err
-	}
-
-	c := &exec.Cmd{
-		Path:   cmd,
-		Args:   arg,
-		Stdin:  r,
-		Stdout: os.Stdout,
-		Stderr: os.Stderr,
-	}
+	return Cmd(arg...).Stdin(r).Run()
+}
 
-	return c.Run()
+// RunWriteContext is the same as RunWrite, but kills the command if ctx is done before it completes
+func RunWriteContext(ctx context.Context, r io.Reader, arg ...string) error {
+	return Cmd(arg...).Context(ctx).Stdin(r).Run()
 }
 
 // RunRead executes the command in background and returns its output as a stream.
 // Close the stream to kill the command and release its resources.
 func RunRead(arg ...string) (Pipe, error) {
-	if len(arg) == 0 {
-		return nil, ErrCommandMissing
-	}
-
-	cmd, err := exec.LookPath(arg[0])
-	if err != nil {
-		return nil, err
-	}
-
-	c := &exec.Cmd{
-		Path:   cmd,
-		Args:   arg,
-		Dir:    "/",
-		Stderr: os.Stderr,
-	}
-
-	r, err := c.StdoutPipe()
-	if err != nil {
-		return nil, err
-	}
-
-	if err = c.Start(); err != nil {
-		return nil, err
-	}
-
-	re := newProcessPipe(r, c)
+	return Cmd(arg...).Dir("/").Read()
+}
 
-	return re, nil
+// RunReadContext is the same as RunRead, but kills the command if ctx is done before it completes
+func RunReadContext(ctx context.Context, arg ...string) (Pipe, error) {
+	return Cmd(arg...).Context(ctx).Dir("/").Read()
 }
 
 // RunPipe runs a command in background, connecting both ends
 func RunPipe(r io.Reader, arg ...string) (Pipe, error) {
-	if len(arg) == 0 {
-		return nil, ErrCommandMissing
-	}
-
-	cmd, err := exec.LookPath(arg[0])
-	if err != nil {
-		return nil, err
-	}
-
-	c := &exec.Cmd{
-		Path:   cmd,
-		Args:   arg,
-		Dir:    "/",
-		Stdin:  r,
-		Stderr: os.Stderr,
-	}
-
-	out, err := c.StdoutPipe()
-	if err != nil {
-		return nil, err
-	}
-
-	if err = c.Start(); err != nil {
-		return nil, err
-	}
-
-	re := newProcessPipe(out, c)
+	return Cmd(arg...).Dir("/").Stdin(r).Pipe()
+}
 
-	return re, nil
+// RunPipeContext is the same as RunPipe, but kills the command if ctx is done before it completes
+func RunPipeContext(ctx context.Context, r io.Reader, arg ...string) (Pipe, error) {
+	return Cmd(arg...).Context(ctx).Dir("/").Stdin(r).Pipe()
 }
 
 // RunGet executes the command and returns its output as a buffer after it completes.
 func RunGet(arg ...string) ([]byte, error) {
-	if len(arg) == 0 {
-		return nil, ErrCommandMissing
-	}
-
-	cmd, err := exec.LookPath(arg[0])
-	if err != nil {
-		return nil, err
-	}
-
-	c := &exec.Cmd{
-		Path: cmd,
-		Args: arg,
-	}
+	return Cmd(arg...).Get()
+}
 
-	return c.Output()
+// RunGetContext is the same as RunGet, but kills the command if ctx is done before it completes
+func RunGetContext(ctx context.Context, arg ...string) ([]byte, error) {
+	return Cmd(arg...).Context(ctx).Get()
 }
 
 // RunJson executes the command and applies its output to the specified object, parsing json data
 func RunJson(obj interface{}, arg ...string) error {
-	r, err := RunRead(arg...)
-	if err != nil {
-		return err
-	}
-	defer r.Close() // close pipe after we finish reading
+	return Cmd(arg...).Dir("/").Json(obj)
+}
 
-	// parse
-	dec := json.NewDecoder(r)
-	return dec.Decode(obj)
+// RunJsonContext is the same as RunJson, but kills the command if ctx is done before it completes
+func RunJsonContext(ctx context.Context, obj interface{}, arg ...string) error {
+	return Cmd(arg...).Context(ctx).Dir("/").Json(obj)
 }
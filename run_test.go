@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os/exec"
 	"testing"
+	"time"
 )
 
 func TestRun(t *testing.T) {
@@ -184,12 +185,95 @@ func TestRemote(t *testing.T) {
 		t.Errorf("error: was expecting an error, got no error")
 		return
 	}
-	// err = signal: broken pipe
-	if err.Error() != "signal: broken pipe" {
-		t.Errorf("error: was expecting broken pipe error, got %s", err)
+	// xz is killed by SIGPIPE, which is expected as we closed its output
+	if !errors.Is(err, ErrClosedByReader) {
+		t.Errorf("error: was expecting ErrClosedByReader, got %s", err)
 	}
 
 	if string(buf) != "pax_global_header" {
 		t.Errorf("failed to run test: invalid output (unexpected result)")
 	}
 }
+
+func TestRunContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	res, err := RunReadContext(ctx, "/bin/sh", "-c", "echo -n hello; exec sleep 10")
+	if err != nil {
+		t.Errorf("failed to run test: %s", err)
+		return
+	}
+
+	buf, err := ioutil.ReadAll(res)
+	if string(buf) != "hello" {
+		t.Errorf("failed, buf did not contain the expected stuff")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("failed, the command was supposed to return a deadline error, got %v", err)
+	}
+
+	var e *exec.ExitError
+	if !errors.As(err, &e) {
+		t.Errorf("failed, the command was supposed to return an error of type exec.ExitError, got %T (%s)", err, err)
+	}
+
+	// a command failing by itself should not be reported as a context error
+	_, err = RunGetContext(context.Background(), "false")
+	if err == nil || errors.Is(err, context.Canceled) {
+		t.Errorf("failed, expected a plain command error, got %v", err)
+	}
+}
+
+func TestEarlyClose(t *testing.T) {
+	// the command is killed by SIGPIPE once we close its output
+	res, err := RunRead("yes")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(res, buf); err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	err = res.CloseWait(context.Background())
+	if !errors.Is(err, ErrClosedByReader) {
+		t.Errorf("failed, was expecting ErrClosedByReader, got %v", err)
+	}
+	var e *exec.ExitError
+	if !errors.As(err, &e) {
+		t.Errorf("failed, was expecting an exec.ExitError, got %T", err)
+	}
+
+	res, err = Cmd("yes").IgnoreEarlyClose().Read()
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	io.ReadFull(res, buf)
+	if err = res.CloseWait(context.Background()); err != nil {
+		t.Errorf("failed, was expecting no error, got %v", err)
+	}
+
+	// real failures are still returned
+	res, err = RunRead("/bin/sh", "-c", "echo hello; exec 1>&-; sleep 0.1; exit 3")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	io.ReadFull(res, buf)
+	err = res.CloseWait(context.Background())
+	if !errors.As(err, &e) || e.ExitCode() != 3 || errors.Is(err, ErrClosedByReader) {
+		t.Errorf("failed, was expecting exit status 3, got %v", err)
+	}
+
+	// in a pipeline, the stages writing to a closed pipe are ignored
+	res, err = NewPipeline(CmdStage("yes"), FuncStage(func(r io.Reader, w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	}), CmdStage("cat")).Start(nil)
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	io.ReadFull(res, buf)
+	if err = res.CloseWait(context.Background()); err != ErrClosedByReader {
+		t.Errorf("failed, was expecting ErrClosedByReader, got %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build windows
+
+package runutil
+
+func (x *execution) setupUser(name string) error {
+	return ErrNotSupported
+}
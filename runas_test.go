@@ -0,0 +1,39 @@
+//go:build !windows
+
+package runutil
+
+import (
+	"os"
+	"os/user"
+	"testing"
+)
+
+func TestRunAs(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing user requires root")
+	}
+	u, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skipf("user nobody not found: %s", err)
+	}
+
+	for _, name := range []string{"nobody", u.Uid} {
+		res, err := Cmd("/bin/sh", "-c", "echo -n $(id -u):$(id -g) $USER $LOGNAME $HOME $FOO").
+			Dir("/").
+			Env(NewEnv("/", "FOO=bar")).
+			RunAs(name).
+			Get()
+		if err != nil {
+			t.Errorf("failed to run test: %s", err)
+			continue
+		}
+		expect := u.Uid + ":" + u.Gid + " nobody nobody " + u.HomeDir + " bar"
+		if string(res) != expect {
+			t.Errorf("invalid output, expected %q, got %q", expect, res)
+		}
+	}
+
+	if _, err := Cmd("true").RunAs("runutil-no-such-user").Get(); err == nil {
+		t.Errorf("failed, an unknown user was supposed to return an error")
+	}
+}
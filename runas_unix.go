@@ -0,0 +1,85 @@
+//go:build !windows
+
+package runutil
+
+import (
+	"bufio"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setupUser sets the credentials of the command and its environment for the given
+// user name or uid
+func (x *execution) setupUser(name string) error {
+	u, err := lookupUser(name)
+	if err != nil {
+		return err
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return err
+	}
+	ids, err := u.GroupIds()
+	if err != nil {
+		return err
+	}
+	groups := make([]uint32, 0, len(ids))
+	for _, s := range ids {
+		g, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, uint32(g))
+	}
+
+	x.cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: groups}
+
+	// copy so we don't modify the Env passed to the Command
+	env := append(Env(nil), x.cmd.Env...)
+	env.Set("HOME", u.HomeDir)
+	env.Set("USER", u.Username)
+	env.Set("LOGNAME", u.Username)
+	env.Set("SHELL", userShell(u.Username))
+	x.cmd.Env = []string(env)
+	return nil
+}
+
+// lookupUser finds a user by name, or by uid if name is numeric and no such user exists
+func lookupUser(name string) (*user.User, error) {
+	u, err := user.Lookup(name)
+	if err == nil {
+		return u, nil
+	}
+	if _, err2 := strconv.ParseUint(name, 10, 32); err2 == nil {
+		return user.LookupId(name)
+	}
+	return nil, err
+}
+
+// userShell returns the login shell of the user from /etc/passwd, as os/user doesn't
+// provide it
+func userShell(name string) string {
+	f, err := os.Open("/etc/passwd")
+	if err != nil {
+		return "/bin/sh"
+	}
+	defer f.Close()
+
+	// name:password:uid:gid:gecos:home:shell
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Split(s.Text(), ":")
+		if len(fields) == 7 && fields[0] == name && fields[6] != "" {
+			return fields[6]
+		}
+	}
+	return "/bin/sh"
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package runutil
+
+func (x *execution) setupSandbox(s *Sandbox) error {
+	return ErrNotSupported
+}
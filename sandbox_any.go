@@ -0,0 +1,20 @@
+package runutil
+
+// Sandbox describes how a command is isolated with Command.Sandbox. The command runs
+// as root inside a new user namespace mapped to the calling user, as PID 1 of a new
+// pid namespace with its own /proc, and in a network namespace only having a
+// loopback interface.
+//
+// Being PID 1, the command only receives the signals it handles, except SIGKILL.
+type Sandbox struct {
+	Root     string  // if set, the command is chrooted there. Root must contain a proc directory
+	Mounts   []Mount // bind mounts to make available in the sandbox
+	Hostname string  // hostname in the sandbox, defaults to "sandbox"
+}
+
+// Mount is a bind mount made available in a Sandbox
+type Mount struct {
+	Source   string // path on the host
+	Target   string // path in the sandbox, relative to its Root. Defaults to Source
+	ReadOnly bool
+}
@@ -0,0 +1,131 @@
+package runutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// setupSandbox has the command started through the helper in new namespaces
+func (x *execution) setupSandbox(s *Sandbox) error {
+	cfg := x.useHelper()
+	cfg.Sandbox = s
+	cfg.Dir = x.cmd.Dir
+	x.cmd.Dir = ""
+
+	attr := x.cmd.SysProcAttr
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNET | syscall.CLONE_NEWUTS | syscall.CLONE_NEWIPC
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+	return nil
+}
+
+// sandboxSetup runs in the helper and sets up the namespaces, then changes to dir
+func sandboxSetup(s *Sandbox, dir string) error {
+	// don't propagate our mounts to the host
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+
+	root := s.Root
+	if root == "" {
+		root = "/"
+	}
+	for _, m := range s.Mounts {
+		if err := bindMount(root, m); err != nil {
+			return err
+		}
+	}
+
+	if err := syscall.Mount("proc", filepath.Join(root, "proc"), "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("failed to mount proc: %w", err)
+	}
+
+	hostname := s.Hostname
+	if hostname == "" {
+		hostname = "sandbox"
+	}
+	if err := syscall.Sethostname([]byte(hostname)); err != nil {
+		return fmt.Errorf("failed to set hostname: %w", err)
+	}
+
+	if err := loopbackUp(); err != nil {
+		return fmt.Errorf("failed to bring lo up: %w", err)
+	}
+
+	if s.Root != "" {
+		if err := syscall.Chroot(s.Root); err != nil {
+			return fmt.Errorf("failed to chroot: %w", err)
+		}
+	}
+	if dir == "" {
+		dir = "/"
+	}
+	if err := syscall.Chdir(dir); err != nil {
+		return fmt.Errorf("failed to change directory: %w", err)
+	}
+	return nil
+}
+
+// bindMount mounts m under root
+func bindMount(root string, m Mount) error {
+	target := m.Target
+	if target == "" {
+		target = m.Source
+	}
+	target = filepath.Join(root, target)
+
+	if err := syscall.Mount(m.Source, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("failed to mount %s: %w", m.Source, err)
+	}
+	if !m.ReadOnly {
+		return nil
+	}
+
+	// remounting must keep the flags locked by the user namespace
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(target, &st); err != nil {
+		return err
+	}
+	flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+	flags |= uintptr(st.Flags) & (syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC | syscall.MS_NOATIME | syscall.MS_NODIRATIME)
+	if st.Flags&_ST_RELATIME != 0 {
+		flags |= syscall.MS_RELATIME
+	}
+	if err := syscall.Mount("", target, "", flags, ""); err != nil {
+		return fmt.Errorf("failed to make %s read-only: %w", target, err)
+	}
+	return nil
+}
+
+// _ST_RELATIME is the statfs flag matching MS_RELATIME
+const _ST_RELATIME = 4096
+
+// loopbackUp sets the loopback interface of the network namespace up
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	// struct ifreq, with the union large enough on every arch
+	var ifr struct {
+		name  [syscall.IFNAMSIZ]byte
+		flags uint16
+		_     [24]byte
+	}
+	copy(ifr.name[:], "lo")
+
+	if _, _, e := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCGIFFLAGS, uintptr(unsafe.Pointer(&ifr))); e != 0 {
+		return e
+	}
+	ifr.flags |= syscall.IFF_UP
+	if _, _, e := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&ifr))); e != 0 {
+		return e
+	}
+	return nil
+}
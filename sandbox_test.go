@@ -0,0 +1,131 @@
+//go:build linux
+
+package runutil
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// checkSandbox skips the test if user namespaces aren't available
+func checkSandbox(t *testing.T) {
+	err := Cmd("true").Sandbox(&Sandbox{}).Run()
+	if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOSPC) {
+		t.Skipf("user namespaces not available: %s", err)
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	checkSandbox(t)
+
+	res, err := Cmd("/bin/sh", "-c", "echo $$ $(id -u) $(hostname) $PWD $FOO; tail -n +3 /proc/net/dev | cut -d: -f1").
+		Dir("/tmp").
+		Env(Env{"FOO=bar", "PATH=/usr/bin:/bin"}).
+		Sandbox(&Sandbox{Hostname: "box"}).
+		Get()
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	if s := strings.Join(strings.Fields(string(res)), " "); s != "1 0 box /tmp bar lo" {
+		t.Errorf("invalid sandbox, got %q", res)
+	}
+
+	// only the processes of the sandbox should be visible
+	r, err := Cmd("ls", "/proc").Sandbox(&Sandbox{}).Read()
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		t.Errorf("failed to run test: %s", err)
+	}
+	for _, s := range strings.Fields(string(buf)) {
+		if s[0] >= '0' && s[0] <= '9' && s != "1" {
+			t.Errorf("failed, process %s is visible in the sandbox", s)
+		}
+	}
+
+	var v []string
+	if err := Cmd("echo", `["a","b"]`).Sandbox(&Sandbox{}).Json(&v); err != nil || len(v) != 2 {
+		t.Errorf("failed to run json command in sandbox: %v %v", v, err)
+	}
+
+	_, err = Cmd("true").Sandbox(&Sandbox{Mounts: []Mount{{Source: "/nonexistent-runutil"}}}).Get()
+	if err == nil || !strings.HasPrefix(err.Error(), "sandbox: ") {
+		t.Errorf("failed, an invalid mount was supposed to fail, got %v", err)
+	}
+}
+
+func TestSandboxRoot(t *testing.T) {
+	checkSandbox(t)
+
+	root := t.TempDir()
+	data := t.TempDir()
+	s := &Sandbox{Root: root, Mounts: []Mount{{Source: data, Target: "/data"}}}
+	for _, d := range []string{"proc", "data"} {
+		if err := os.Mkdir(filepath.Join(root, d), 0755); err != nil {
+			t.Fatalf("failed to run test: %s", err)
+		}
+	}
+	// make the host's binaries available read-only
+	for _, d := range []string{"bin", "sbin", "lib", "lib32", "lib64", "usr"} {
+		fi, err := os.Lstat("/" + d)
+		if err != nil {
+			continue
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			l, _ := os.Readlink("/" + d)
+			os.Symlink(l, filepath.Join(root, d))
+			continue
+		}
+		os.Mkdir(filepath.Join(root, d), 0755)
+		s.Mounts = append(s.Mounts, Mount{Source: "/" + d, ReadOnly: true})
+	}
+
+	res, err := Cmd("sh", "-c", "ls /; echo hello > /data/out; touch /usr/runutil-test || echo read-only").
+		Env(Env{"PATH=/usr/bin:/bin"}).
+		Sandbox(s).
+		Get()
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	if !strings.Contains(string(res), "data\n") || !strings.HasSuffix(string(res), "read-only\n") {
+		t.Errorf("invalid output: %q", res)
+	}
+	if buf, _ := os.ReadFile(filepath.Join(data, "out")); string(buf) != "hello\n" {
+		t.Errorf("failed, file written in the sandbox not found, got %q", buf)
+	}
+
+	// commands are looked up in the sandbox, including with a context
+	if err := os.WriteFile(filepath.Join(data, "onlyinroot"), []byte("#!/bin/sh\necho inside\n"), 0755); err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	res, err = Cmd("onlyinroot").Env(Env{"PATH=/data:/usr/bin:/bin"}).Timeout(time.Minute).Sandbox(s).Get()
+	if err != nil || string(res) != "inside\n" {
+		t.Errorf("failed to run command found in the sandbox: %q %v", res, err)
+	}
+}
+
+func TestHelperEnv(t *testing.T) {
+	// the helper variable is ignored when not set by runutil
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), helperEnv+`={"Path":"/bin/false","Pipe":1}`)
+	cmd.ExtraFiles = []*os.File{w}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("failed, the program was taken over by the helper: %s %q", err, out)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !linux
+
+package runutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// Signal sends sig to the process pid
+func Signal(pid int, sig syscall.Signal) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Signal(sig)
+}
+
+// SignalProc sends sig to p
+func SignalProc(p *Proc, sig syscall.Signal) error {
+	return Signal(p.Pid, sig)
+}
+
+// KillAll sends sig to all the processes found by PidOf(name), similar to killall,
+// and returns the number of processes that were signalled.
+func KillAll(name string, sig syscall.Signal) (int, error) {
+	var n int
+	for _, pid := range PidOf(name) {
+		if err := Signal(pid, sig); err != nil {
+			return n, err
+		}
+		n += 1
+	}
+	return n, nil
+}
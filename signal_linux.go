@@ -0,0 +1,71 @@
+package runutil
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Signal sends sig to the process pid. On kernels supporting it, a pidfd is used so
+// that the signal can't reach another process if pid is reused.
+func Signal(pid int, sig syscall.Signal) error {
+	st, err := LinuxPidState(uint64(pid))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+	return signalChecked(pid, st.StartTime, sig)
+}
+
+// SignalProc sends sig to p, after making sure the process p refers to is still the
+// one found when the snapshot was taken.
+func SignalProc(p *Proc, sig syscall.Signal) error {
+	st, ok := p.State.(*LinuxProcState)
+	if !ok {
+		return Signal(p.Pid, sig)
+	}
+	return signalChecked(p.Pid, st.StartTime, sig)
+}
+
+// KillAll sends sig to all the processes found by PidOf(name), similar to killall,
+// and returns the number of processes that were signalled. Processes that exited
+// before being signalled are ignored.
+func KillAll(name string, sig syscall.Signal) (int, error) {
+	var n int
+	var errs []error
+
+	for _, pid := range PidOf(name) {
+		if pid == os.Getpid() {
+			continue
+		}
+		st, err := LinuxPidState(uint64(pid))
+		if err != nil {
+			// process is gone
+			continue
+		}
+
+		err = signalChecked(pid, st.StartTime, sig)
+		switch {
+		case err == nil:
+			n += 1
+		case errors.Is(err, os.ErrProcessDone), errors.Is(err, ErrProcessChanged):
+		default:
+			errs = append(errs, err)
+		}
+	}
+
+	return n, errors.Join(errs...)
+}
+
+// signalChecked sends sig to pid if it still has the given start time
+func signalChecked(pid int, startTime uint64, sig syscall.Signal) error {
+	h, err := openProcess(pid, startTime)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	return h.Signal(sig)
+}
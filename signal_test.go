@@ -0,0 +1,156 @@
+//go:build linux
+
+package runutil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSignal(t *testing.T) {
+	p, err := Start("sleep", "10")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+
+	tbl, err := Processes()
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	proc := tbl[p.Pid()]
+	if proc == nil {
+		t.Fatalf("sleep process not found")
+	}
+
+	// pretend the process was replaced after the snapshot
+	st := *proc.State.(*LinuxProcState)
+	st.StartTime -= 1
+	if err := SignalProc(&Proc{Pid: proc.Pid, State: &st}, syscall.SIGTERM); !errors.Is(err, ErrProcessChanged) {
+		t.Errorf("expected ErrProcessChanged, got %v", err)
+	}
+
+	if err := SignalProc(proc, syscall.SIGTERM); err != nil {
+		t.Errorf("failed to signal: %s", err)
+	}
+
+	var e *exec.ExitError
+	if err := p.Wait(); !errors.As(err, &e) || e.ProcessState.String() != "signal: terminated" {
+		t.Errorf("expected process to be terminated, got %v", err)
+	}
+
+	if err := Signal(p.Pid(), syscall.SIGTERM); !errors.Is(err, os.ErrProcessDone) {
+		t.Errorf("expected ErrProcessDone, got %v", err)
+	}
+}
+
+func TestKillAll(t *testing.T) {
+	// use a copy of sleep with a name that won't match anything else on the system
+	src, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	buf, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	bin := filepath.Join(t.TempDir(), "runutil-test-sleep")
+	if err := os.WriteFile(bin, buf, 0755); err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+
+	var procs []*Process
+	for i := 0; i < 2; i++ {
+		p, err := Start(bin, "10")
+		if err != nil {
+			t.Fatalf("failed to run test: %s", err)
+		}
+		procs = append(procs, p)
+	}
+
+	n, err := KillAll("runutil-test-sleep", syscall.SIGKILL)
+	if err != nil {
+		t.Errorf("failed to kill: %s", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 processes to be killed, got %d", n)
+	}
+
+	for _, p := range procs {
+		var e *exec.ExitError
+		if err := p.Wait(); !errors.As(err, &e) || e.ProcessState.String() != "signal: killed" {
+			t.Errorf("expected process to be killed, got %v", err)
+		}
+	}
+}
+
+func TestProcessHandle(t *testing.T) {
+	p, err := Start("sleep", "0.2")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	defer p.Wait()
+
+	h, err := OpenProcess(p.Pid())
+	if err != nil {
+		t.Fatalf("failed to open process: %s", err)
+	}
+	defer h.Close()
+
+	if st, err := h.State(); err != nil || !st.Alive() {
+		t.Errorf("process should be alive, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := h.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected wait to time out, got %v", err)
+	}
+
+	// the process exiting should wake us up, even if it wasn't reaped yet
+	start := time.Now()
+	if err := h.Wait(context.Background()); err != nil {
+		t.Errorf("failed to wait: %s", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("wait took too long")
+	}
+	if st, err := h.State(); err != nil || st.State() != ProcZombie {
+		t.Errorf("process should be a zombie, got %v", err)
+	}
+
+	p.Wait()
+	if _, err := h.State(); !errors.Is(err, os.ErrProcessDone) {
+		t.Errorf("expected ErrProcessDone after reaping, got %v", err)
+	}
+}
+
+func TestProcessHandlePoll(t *testing.T) {
+	p, err := Start("sleep", "10")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+
+	// simulate a kernel without pidfd
+	h, err := OpenProcess(p.Pid())
+	if err != nil {
+		t.Fatalf("failed to open process: %s", err)
+	}
+	h.Close()
+	h.f = nil
+
+	if err := h.Signal(syscall.SIGKILL); err != nil {
+		t.Errorf("failed to signal: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := h.Wait(ctx); err != nil {
+		t.Errorf("failed to wait: %s", err)
+	}
+	p.Wait()
+}
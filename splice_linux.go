@@ -0,0 +1,90 @@
+package runutil
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	_SPLICE_F_MOVE     = 0x1
+	_SPLICE_F_NONBLOCK = 0x2
+
+	spliceChunk = 1 << 20
+)
+
+// spliceFile copies src to dst with splice(2), which requires one of them to be a
+// pipe. If splice can't be used, handled is false and nothing has been copied.
+func spliceFile(dst, src *os.File) (written int64, handled bool, err error) {
+	rc, err := src.SyscallConn()
+	if err != nil {
+		return 0, false, nil
+	}
+	wc, err := dst.SyscallConn()
+	if err != nil {
+		return 0, false, nil
+	}
+	if !spliceable(rc) || !spliceable(wc) {
+		return 0, false, nil
+	}
+
+	for {
+		var n int64
+		var serr error
+		rerr := rc.Read(func(rfd uintptr) bool {
+			srcEmpty := false
+			werr := wc.Write(func(wfd uintptr) bool {
+				for {
+					n, serr = syscall.Splice(int(rfd), nil, int(wfd), nil, spliceChunk, _SPLICE_F_MOVE|_SPLICE_F_NONBLOCK)
+					if serr != syscall.EINTR {
+						break
+					}
+				}
+				if serr != syscall.EAGAIN {
+					return true
+				}
+				// find out which side would block, and have the poller wait for it
+				if pollFd(int(wfd), _POLLOUT) {
+					srcEmpty = true
+					return true
+				}
+				return false
+			})
+			if werr != nil {
+				serr = werr
+			}
+			return !srcEmpty || werr != nil
+		})
+		if rerr != nil {
+			return written, true, rerr
+		}
+		if serr != nil {
+			if written == 0 && serr == syscall.EINVAL {
+				// not supported by these files, such as a terminal
+				return 0, false, nil
+			}
+			return written, true, os.NewSyscallError("splice", serr)
+		}
+		if n == 0 {
+			return written, true, nil
+		}
+		written += n
+	}
+}
+
+// spliceable returns true if waiting on c when splice returns EAGAIN is possible.
+// This is the case of files in non-blocking mode, which are registered with go's
+// poller, and of regular files, which never block. Other files, such as a blocking
+// pipe given to os.NewFile, can't be waited on.
+func spliceable(c syscall.RawConn) bool {
+	ok := false
+	c.Control(func(fd uintptr) {
+		var st syscall.Stat_t
+		if syscall.Fstat(int(fd), &st) == nil && st.Mode&syscall.S_IFMT == syscall.S_IFREG {
+			ok = true
+			return
+		}
+		fl, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_GETFL, 0)
+		ok = errno == 0 && fl&syscall.O_NONBLOCK != 0
+	})
+	return ok
+}
@@ -0,0 +1,187 @@
+//go:build linux
+
+package runutil
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestPipeHandoff(t *testing.T) {
+	// the output of the first command becomes the input of the second one
+	up, err := RunRead("/bin/sh", "-c", "readlink /proc/$$/fd/1")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	res, err := Cmd("/bin/sh", "-c", "readlink /proc/$$/fd/0; cat").Stdin(up).Get()
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	lines := strings.Fields(string(res))
+	if len(lines) != 2 || lines[0] != lines[1] {
+		t.Errorf("failed, the pipe wasn't handed off: %q", res)
+	}
+
+	// errors of the upstream command are kept
+	up, _ = RunRead("/bin/sh", "-c", "echo hello; exit 3")
+	down, err := RunPipe(up, "cat")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	buf, err := io.ReadAll(down)
+	if string(buf) != "hello\n" {
+		t.Errorf("invalid output: %q", buf)
+	}
+	var e *exec.ExitError
+	if !errors.As(err, &e) || e.ExitCode() != 3 {
+		t.Errorf("failed, was expecting exit status 3, got %v", err)
+	}
+
+	// but not the expected SIGPIPE if the downstream command stopped reading
+	up, _ = RunRead("yes")
+	res, err = Cmd("head", "-c", "4").Stdin(up).Get()
+	if err != nil || string(res) != "y\ny\n" {
+		t.Errorf("failed, expected y\\ny\\n and no error, got %q %v", res, err)
+	}
+
+	// a Pipe set as Stdin and then ignored is left untouched
+	up, _ = RunRead("echo", "hello")
+	w, err := Cmd("true").Stdin(up).Writer()
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("failed to run test: %s", err)
+	}
+	if buf, err := io.ReadAll(up); err != nil || string(buf) != "hello\n" {
+		t.Errorf("failed, expected the Pipe to still be readable, got %q %v", buf, err)
+	}
+
+	// pipelines can be handed off too
+	p, err := NewPipeline(CmdStage("echo", "hello"), CmdStage("tr", "a-z", "A-Z")).Start(nil)
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	res, err = Cmd("cat").Stdin(p).Get()
+	if err != nil || string(res) != "HELLO\n" {
+		t.Errorf("failed, expected HELLO, got %q %v", res, err)
+	}
+}
+
+func TestPipeSplice(t *testing.T) {
+	data := make([]byte, 3<<20)
+	rand.Read(data)
+
+	r, err := RunPipe(bytes.NewReader(data), "cat")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	defer out.Close()
+
+	n, err := io.Copy(out, r)
+	if err != nil || n != int64(len(data)) {
+		t.Errorf("failed to copy to file: %d %v", n, err)
+	}
+	if buf, _ := os.ReadFile(out.Name()); !bytes.Equal(buf, data) {
+		t.Errorf("failed, invalid data copied to file")
+	}
+
+	// pipe to pipe, with a slow reader
+	r, _ = RunPipe(bytes.NewReader(data), "cat")
+	pr, pw, _ := os.Pipe()
+	go func() {
+		r.CopyTo(pw)
+		pw.Close()
+	}()
+	res, err := Cmd("/bin/sh", "-c", "sleep 0.1; cat").Stdin(struct{ io.Reader }{pr}).Get()
+	if err != nil || !bytes.Equal(res, data) {
+		t.Errorf("failed, invalid data copied to pipe (%d bytes): %v", len(res), err)
+	}
+
+	// blocking pipes can't be waited on, and are copied normally
+	var fds [2]int
+	if err := syscall.Pipe2(fds[:], syscall.O_CLOEXEC); err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	br, bw := os.NewFile(uintptr(fds[0]), "r"), os.NewFile(uintptr(fds[1]), "w")
+	defer br.Close()
+	r, _ = RunPipe(bytes.NewReader(data), "cat")
+	go func() {
+		r.CopyTo(bw)
+		bw.Close()
+	}()
+	buf := &bytes.Buffer{}
+	w, err := RunWriter(buf, "/bin/sh", "-c", "sleep 0.1; cat")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	if _, err := io.Copy(w, br); err != nil {
+		t.Errorf("failed to copy from a blocking pipe: %s", err)
+	}
+	if err := w.Close(); err != nil || !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("failed, invalid data copied through a blocking pipe (%d bytes): %v", buf.Len(), err)
+	}
+
+	// errors are still returned
+	r, _ = RunRead("/bin/sh", "-c", "echo hello; exit 3")
+	var e *exec.ExitError
+	if _, err := r.CopyTo(out); !errors.As(err, &e) || e.ExitCode() != 3 {
+		t.Errorf("failed, was expecting exit status 3, got %v", err)
+	}
+}
+
+// benchmarkChain runs gzip | base64 on 64MB of random data, with the output of gzip
+// optionally hidden so it has to be copied
+func benchmarkChain(b *testing.B, copy bool) {
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatalf("failed to run benchmark: %s", err)
+	}
+	defer null.Close()
+
+	var start syscall.Rusage
+	syscall.Getrusage(syscall.RUSAGE_SELF, &start)
+
+	for i := 0; i < b.N; i++ {
+		gz, err := RunRead("/bin/sh", "-c", "head -c 64M /dev/urandom | gzip -1")
+		if err != nil {
+			b.Fatalf("failed to run benchmark: %s", err)
+		}
+		var in io.Reader = gz
+		if copy {
+			in = struct{ io.Reader }{gz}
+		}
+		r, err := Cmd("base64").Stdin(in).Read()
+		if err != nil {
+			b.Fatalf("failed to run benchmark: %s", err)
+		}
+		if _, err := r.CopyTo(null); err != nil {
+			b.Fatalf("failed to run benchmark: %s", err)
+		}
+	}
+
+	var end syscall.Rusage
+	syscall.Getrusage(syscall.RUSAGE_SELF, &end)
+	cpu := syscall.TimevalToNsec(end.Utime) + syscall.TimevalToNsec(end.Stime) - syscall.TimevalToNsec(start.Utime) - syscall.TimevalToNsec(start.Stime)
+	b.ReportMetric(float64(cpu)/float64(b.N), "cpu-ns/op")
+}
+
+func BenchmarkChainHandoff(b *testing.B) {
+	benchmarkChain(b, false)
+}
+
+func BenchmarkChainCopy(b *testing.B) {
+	benchmarkChain(b, true)
+}
@@ -0,0 +1,70 @@
+package runutil
+
+import (
+	"strings"
+	"sync"
+)
+
+// DefaultStderrTail is the amount of stderr kept by CaptureStderr when no size is given
+const DefaultStderrTail = 64 * 1024
+
+// ExitError is returned when a command using CaptureStderr fails, and holds the
+// last bytes the command wrote to its stderr. errors.As can still be used to
+// reach the underlying *exec.ExitError.
+type ExitError struct {
+	Err    error  // original error, typically *exec.ExitError
+	Stderr []byte // tail of the command's stderr
+}
+
+func (e *ExitError) Error() string {
+	msg := strings.TrimSpace(string(e.Stderr))
+	if msg == "" {
+		return e.Err.Error()
+	}
+	// only keep the last line, which is usually the one explaining what happened
+	if p := strings.LastIndexByte(msg, '\n'); p != -1 {
+		msg = msg[p+1:]
+	}
+	return e.Err.Error() + ": " + msg
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// tailBuffer is a writer that only keeps the last max bytes written to it
+type tailBuffer struct {
+	lk  sync.Mutex
+	buf []byte
+	max int
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	n := len(p)
+	if n >= t.max {
+		t.buf = append(t.buf[:0], p[n-t.max:]...)
+		return n, nil
+	}
+
+	if over := len(t.buf) + n - t.max; over > 0 {
+		// drop the oldest bytes
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+	}
+	t.buf = append(t.buf, p...)
+	return n, nil
+}
+
+// Bytes returns a copy of the data currently held by the buffer
+func (t *tailBuffer) Bytes() []byte {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	return append([]byte(nil), t.buf...)
+}
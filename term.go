@@ -0,0 +1,60 @@
+package runutil
+
+import (
+	"syscall"
+	"time"
+)
+
+// TermPolicy defines how a running command is stopped, either when its context is
+// done or when its output is closed before it completed. Commands with a TermPolicy
+// run in their own process group, and signals are sent to the whole group so
+// processes they spawned are stopped too.
+//
+// The zero value kills the command straight away with SIGKILL.
+type TermPolicy struct {
+	Signal       syscall.Signal // first signal sent to the command, SIGKILL if zero
+	Grace        time.Duration  // time given to the command to exit after Signal, before Final is sent
+	Final        syscall.Signal // signal sent once Grace expired, SIGKILL if zero
+	CloseTimeout time.Duration  // time Close gives the command to exit by itself before terminating it, 10 seconds if zero
+}
+
+// terminate sends the first signal of the policy with signal, and the final one if
+// done hasn't been closed after the grace period
+func (t *TermPolicy) terminate(signal func(syscall.Signal) error, done <-chan struct{}) error {
+	if t == nil {
+		t = &TermPolicy{}
+	}
+
+	sig := t.Signal
+	if sig == 0 {
+		sig = syscall.SIGKILL
+	}
+	err := signal(sig)
+	if sig == syscall.SIGKILL {
+		return err
+	}
+
+	go func() {
+		timer := time.NewTimer(t.Grace)
+		defer timer.Stop()
+
+		select {
+		case <-done:
+		case <-timer.C:
+			final := t.Final
+			if final == 0 {
+				final = syscall.SIGKILL
+			}
+			signal(final)
+		}
+	}()
+
+	return err
+}
+
+func (t *TermPolicy) closeTimeout() time.Duration {
+	if t == nil || t.CloseTimeout <= 0 {
+		return 10 * time.Second
+	}
+	return t.CloseTimeout
+}
@@ -0,0 +1,99 @@
+package runutil
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// Writer is the input of a running command. Closing it closes the command's stdin
+// and waits for it to complete.
+type Writer interface {
+	io.WriteCloser
+	CloseWait(ctx context.Context) error
+}
+
+type processWriter struct {
+	w *os.File
+	x *execution
+}
+
+// RunWriter executes the command in background and returns a Writer feeding its
+// input. Its output goes to out, or os.Stdout if nil. Close returns the error of
+// the command, if any.
+func RunWriter(out io.Writer, arg ...string) (Writer, error) {
+	return Cmd(arg...).Stdout(out).Writer()
+}
+
+// RunWriter executes the command in background and returns a Writer feeding its
+// input. Its output goes to out, or os.Stdout if nil. Close returns the error of
+// the command, if any.
+func (e Env) RunWriter(out io.Writer, arg ...string) (Writer, error) {
+	return Cmd(arg...).Env(e).Stdout(out).Writer()
+}
+
+// Writer executes the command in background and returns a Writer feeding its input.
+// Stdout and stderr default to os.Stdout and os.Stderr, and any Stdin set on the
+// Command is ignored.
+func (c *Command) Writer() (Writer, error) {
+	x, err := c.prepare()
+	if err != nil {
+		return nil, err
+	}
+	x.ownGroup()
+
+	x.cmd.Stdout = c.stdout
+	if x.cmd.Stdout == nil {
+		x.cmd.Stdout = os.Stdout
+	}
+	if x.cmd.Stderr == nil {
+		x.cmd.Stderr = os.Stderr
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, x.finish(err)
+	}
+	x.cmd.Stdin = r
+
+	err = x.startCmd()
+	r.Close()
+	if err != nil {
+		w.Close()
+		return nil, x.finish(err)
+	}
+	return &processWriter{w: w, x: x}, nil
+}
+
+func (p *processWriter) Write(b []byte) (int, error) {
+	return p.w.Write(b)
+}
+
+// ReadFrom allows io.Copy to use splice when copying from a file
+func (p *processWriter) ReadFrom(r io.Reader) (int64, error) {
+	if rf, ok := r.(*os.File); ok {
+		if n, handled, err := spliceFile(p.w, rf); handled {
+			return n, err
+		}
+	}
+	return io.Copy(p.w, r)
+}
+
+// Close closes the input of the command, and waits for it to complete
+func (p *processWriter) Close() error {
+	err := p.w.Close()
+	if e := p.x.wait(); e != nil {
+		return e
+	}
+	return err
+}
+
+// CloseWait closes the input of the command and waits for it to complete. If ctx
+// is done first, the command is terminated.
+func (p *processWriter) CloseWait(ctx context.Context) error {
+	err := p.w.Close()
+	if e := p.x.waitContext(ctx); e != nil {
+		return e
+	}
+	return err
+}
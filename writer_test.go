@@ -0,0 +1,46 @@
+package runutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRunWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := RunWriter(buf, "/bin/sh", "-c", "tr a-z A-Z; exit 2")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	if err := json.NewEncoder(w).Encode(map[string]string{"a": "b"}); err != nil {
+		t.Errorf("failed to write: %s", err)
+	}
+
+	err = w.Close()
+	if buf.String() != "{\"A\":\"B\"}\n" {
+		t.Errorf("invalid output: %q", buf)
+	}
+	var e *exec.ExitError
+	if !errors.As(err, &e) || e.ExitCode() != 2 {
+		t.Errorf("failed, was expecting exit status 2, got %v", err)
+	}
+
+	// a command not exiting once its input is closed is killed
+	w, err = RunWriter(nil, "/bin/sh", "-c", "cat; sleep 10")
+	if err != nil {
+		t.Fatalf("failed to run test: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := w.CloseWait(ctx); err == nil {
+		t.Errorf("failed, the command was supposed to be killed")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("failed, the command wasn't killed in time")
+	}
+}